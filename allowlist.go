@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// IPAllowlist restricts requests to a set of source networks.
+type IPAllowlist struct {
+	Networks []netip.Prefix
+	// TrustProxy takes the client address from X-Forwarded-For instead of
+	// the connection's remote address.
+	TrustProxy bool
+	// TrustedProxies restricts TrustProxy to requests from these networks.
+	// Their addresses are skipped when reading X-Forwarded-For.
+	TrustedProxies []netip.Prefix
+}
+
+// ParseCIDRs parses a comma-separated list of CIDRs. Bare addresses are
+// treated as single-host networks.
+func ParseCIDRs(value string) ([]netip.Prefix, error) {
+	var networks []netip.Prefix
+	for _, entry := range splitList(value) {
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+			}
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		networks = append(networks, prefix.Masked())
+	}
+	return networks, nil
+}
+
+// ClientIP returns the address of the client that sent the request. With
+// TrustProxy, it is the rightmost X-Forwarded-For entry that isn't a trusted
+// proxy: proxies append to the header, so the entries on its left are
+// supplied by the client and can't be trusted.
+func (a *IPAllowlist) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !a.TrustProxy || !a.trusted(host) {
+		return host
+	}
+
+	client := host
+	entries := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := strings.TrimSpace(entries[i])
+		if entry == "" {
+			continue
+		}
+		client = entry
+		// Without TrustedProxies only the entry of the proxy is reliable
+		if len(a.TrustedProxies) == 0 || !a.trusted(entry) {
+			break
+		}
+	}
+	return client
+}
+
+// trusted reports whether addr is a trusted proxy. Every address is trusted
+// when no TrustedProxies are configured.
+func (a *IPAllowlist) trusted(addr string) bool {
+	if len(a.TrustedProxies) == 0 {
+		return true
+	}
+	parsed, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	parsed = parsed.Unmap()
+	for _, network := range a.TrustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether the request comes from an allowed network. An
+// empty allowlist allows everything.
+func (a *IPAllowlist) Allowed(r *http.Request) bool {
+	if len(a.Networks) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(a.ClientIP(r))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, network := range a.Networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects requests from disallowed addresses with 403.
+func (a *IPAllowlist) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Allowed(r) {
+			slog.Warn("Request from disallowed address", "remote_addr", a.ClientIP(r), "path", r.URL.Path)
+			writeError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
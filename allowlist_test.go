@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestIPAllowlistClientIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name      string
+		allowlist IPAllowlist
+		remote    string
+		xff       string
+		want      string
+	}{
+		{"proxy not trusted", IPAllowlist{}, "192.0.2.1:1234", "203.0.113.7", "192.0.2.1"},
+		{"rightmost entry", IPAllowlist{TrustProxy: true}, "10.0.0.2:1234", "198.51.100.1, 203.0.113.7", "203.0.113.7"},
+		{"no header", IPAllowlist{TrustProxy: true}, "10.0.0.2:1234", "", "10.0.0.2"},
+		{"remote is a trusted proxy", IPAllowlist{TrustProxy: true, TrustedProxies: proxies}, "10.0.0.2:1234", "198.51.100.1, 203.0.113.7", "203.0.113.7"},
+		{"remote is not a trusted proxy", IPAllowlist{TrustProxy: true, TrustedProxies: proxies}, "192.0.2.1:1234", "203.0.113.7", "192.0.2.1"},
+		{"trusted proxies skipped", IPAllowlist{TrustProxy: true, TrustedProxies: proxies}, "10.0.0.2:1234", "198.51.100.1, 203.0.113.7, 10.0.0.3", "203.0.113.7"},
+		{"only trusted proxies", IPAllowlist{TrustProxy: true, TrustedProxies: proxies}, "10.0.0.2:1234", "10.0.0.4, 10.0.0.3", "10.0.0.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/webhooks/id", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := tt.allowlist.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPAllowlistRejectsSpoofedForwardedFor(t *testing.T) {
+	allowlist := &IPAllowlist{
+		Networks:   []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")},
+		TrustProxy: true,
+	}
+	r := httptest.NewRequest("POST", "/api/webhooks/id", nil)
+	r.RemoteAddr = "10.0.0.2:1234"
+	// The client supplied the allowed address, the proxy appended the real one
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+	if allowlist.Allowed(r) {
+		t.Error("Allowed() = true for a spoofed X-Forwarded-For entry")
+	}
+}
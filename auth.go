@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// authorized reports whether the request carries one of the given keys as a
+// bearer token in its Authorization header. Empty keys never match.
+func authorized(r *http.Request, keys ...string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	for _, key := range keys {
+		if key != "" && subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWebhookID returns the configured ID matching id, ignoring surrounding
+// whitespace and, when caseInsensitive is set, case. Every configured ID is
+// compared through its hash in constant time, so that response times reveal
+// neither which ID nor how much of it matched.
+func matchWebhookID(ids map[string]bool, id string, caseInsensitive bool) (string, bool) {
+	normalize := func(value string) [sha256.Size]byte {
+		value = strings.TrimSpace(value)
+		if caseInsensitive {
+			value = strings.ToLower(value)
+		}
+		return sha256.Sum256([]byte(value))
+	}
+
+	received := normalize(id)
+	matched, found := "", false
+	for candidate := range ids {
+		expected := normalize(candidate)
+		if subtle.ConstantTimeCompare(received[:], expected[:]) == 1 {
+			matched, found = candidate, true
+		}
+	}
+	return matched, found
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Batcher groups the triggers received for a key during a fixed window into
+// a single call. Unlike the Debouncer, new triggers don't extend the window.
+type Batcher struct {
+	Window time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*batch
+}
+
+type batch struct {
+	fn        func(firstSeen time.Time, count int)
+	firstSeen time.Time
+	count     int
+}
+
+// NewBatcher creates a Batcher with the given window.
+func NewBatcher(window time.Duration) *Batcher {
+	return &Batcher{
+		Window:  window,
+		pending: make(map[string]*batch),
+	}
+}
+
+// Add schedules fn to run when the window of the key closes. The first call
+// opens the window and later calls replace fn, so only the latest one runs.
+// fn receives the time the window opened and the number of batched calls.
+// Add reports whether the call joined an open window.
+func (b *Batcher) Add(key string, fn func(firstSeen time.Time, count int)) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if existing, ok := b.pending[key]; ok {
+		existing.fn = fn
+		existing.count++
+		slog.Debug("Webhook added to batch", "key", key, "count", existing.count)
+		return true
+	}
+
+	entry := &batch{fn: fn, firstSeen: time.Now(), count: 1}
+	b.pending[key] = entry
+	time.AfterFunc(b.Window, func() {
+		b.mu.Lock()
+		delete(b.pending, key)
+		fn, count := entry.fn, entry.count
+		b.mu.Unlock()
+
+		slog.Info("Batch window elapsed - forwarding batched webhooks", "key", key, "webhooks", count)
+		fn(entry.firstSeen, count)
+	})
+	return false
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Categories of Watchtower responses, used as the "category" metric label.
+const (
+	ResponseSuccess     = "success"
+	ResponseAuthError   = "auth_error"
+	ResponseNotFound    = "not_found"
+	ResponseClientError = "client_error"
+	ResponseServerError = "server_error"
+	ResponseUnreachable = "unreachable"
+	// ResponseUnexpected is a response outside SUCCESS_STATUS_CODES or not
+	// matching SUCCESS_BODY_REGEX that would otherwise be a success.
+	ResponseUnexpected = "unexpected"
+)
+
+// SuccessCriteria defines which Watchtower responses count as successful.
+// A nil SuccessCriteria accepts any 2xx status.
+type SuccessCriteria struct {
+	// StatusCodes lists inclusive ranges of successful status codes.
+	StatusCodes [][2]int
+	// BodyRegex must match the response body when set.
+	BodyRegex *regexp.Regexp
+}
+
+// ParseStatusCodes parses a comma-separated list of status codes and ranges,
+// e.g. "200-299,304".
+func ParseStatusCodes(value string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, entry := range splitList(value) {
+		low, high, isRange := strings.Cut(entry, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(low))
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(strings.TrimSpace(high))
+		}
+		if err != nil || from < 100 || to > 599 || from > to {
+			return nil, fmt.Errorf("invalid status code range %q, expected e.g. 200 or 200-299", entry)
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no status codes in %q", value)
+	}
+	return ranges, nil
+}
+
+// Classify returns the category of a Watchtower response, applying the
+// configured success criteria.
+func (c *SuccessCriteria) Classify(status int, body []byte) string {
+	category := ClassifyResponse(status)
+	if c == nil {
+		return category
+	}
+	if len(c.StatusCodes) > 0 {
+		matched := false
+		for _, r := range c.StatusCodes {
+			if status >= r[0] && status <= r[1] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			if category == ResponseSuccess {
+				return ResponseUnexpected
+			}
+			return category
+		}
+	}
+	if c.BodyRegex != nil && !c.BodyRegex.Match(body) {
+		return ResponseUnexpected
+	}
+	return ResponseSuccess
+}
+
+// ClassifyResponse returns the category of a Watchtower response status.
+func ClassifyResponse(status int) string {
+	switch {
+	case status >= 200 && status < 300:
+		return ResponseSuccess
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ResponseAuthError
+	case status == http.StatusNotFound:
+		return ResponseNotFound
+	case status >= 500:
+		return ResponseServerError
+	default:
+		return ResponseClientError
+	}
+}
+
+// logResponseGuidance logs hints to fix the cause of a failed response.
+func logResponseGuidance(logger *slog.Logger, category string, status int, url string) {
+	switch category {
+	case ResponseAuthError:
+		logger.Error("Watchtower rejected the credentials", "status", status, "url", url)
+		logger.Info("Check that WATCHTOWER_API_KEY (or the route api_key) matches WATCHTOWER_HTTP_API_TOKEN on Watchtower")
+	case ResponseNotFound:
+		logger.Error("404 - Watchtower endpoint not found", "url", url)
+		logger.Info("Check WATCHTOWER_PATH and that Watchtower runs with --http-api-update; common endpoints are /v1/update, /api/update, /webhook")
+	case ResponseServerError:
+		logger.Error("Watchtower failed to process the update", "status", status, "url", url)
+		logger.Info("Check the Watchtower logs; the request is retried up to MAX_RETRIES times")
+	case ResponseUnexpected:
+		logger.Warn("Watchtower response does not meet the success criteria", "status", status, "url", url)
+		logger.Info("Check SUCCESS_STATUS_CODES and SUCCESS_BODY_REGEX; Watchtower may not have updated any container")
+	case ResponseClientError:
+		logger.Error("Watchtower rejected the request", "status", status, "url", url)
+		logger.Info("Check FORWARD_METHOD, FORWARD_CONTENT_TYPE and FORWARD_BODY_TEMPLATE")
+	}
+}
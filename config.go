@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings that can be provided through CONFIG_FILE.
+// Environment variables always override values from the file.
+type Config struct {
+	WebhookIDs            []string       `yaml:"webhook_ids"`
+	APIKey                string         `yaml:"api_key"`
+	WatchtowerURLs        []string       `yaml:"watchtower_urls"`
+	Routes                Routes         `yaml:"routes"`
+	WatchOnlyForLatestTag bool           `yaml:"watch_only_for_latest_tag"`
+	WatchTags             []string       `yaml:"watch_tags"`
+	WatchTagRegex         string         `yaml:"watch_tag_regex"`
+	WatchTagSemver        string         `yaml:"watch_tag_semver"`
+	DelaySeconds          int            `yaml:"delay_seconds"`
+	DelayOverrides        map[string]int `yaml:"delay_overrides"`
+}
+
+// LoadConfig reads the optional YAML file at path, applies environment
+// overrides and validates the result.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{DelaySeconds: 20}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+		cfg.expandEnv()
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// expandEnv replaces ${VAR} references in the string values read from the
+// config file with the environment, so that secrets can stay out of the file.
+func (c *Config) expandEnv() {
+	c.APIKey = expandEnv(c.APIKey)
+	c.WatchTagRegex = expandEnv(c.WatchTagRegex)
+	c.WatchTagSemver = expandEnv(c.WatchTagSemver)
+	for _, list := range [][]string{c.WebhookIDs, c.WatchtowerURLs, c.WatchTags} {
+		for i := range list {
+			list[i] = expandEnv(list[i])
+		}
+	}
+	for repo, route := range c.Routes {
+		route.Target = expandEnv(route.Target)
+		route.APIKey = expandEnv(route.APIKey)
+		c.Routes[repo] = route
+	}
+}
+
+// expandEnv expands $VAR and ${VAR} in value, with $$ escaping a literal $.
+func expandEnv(value string) string {
+	return os.Expand(value, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return getEnv(name)
+	})
+}
+
+// applyEnv overrides file values with the matching environment variables.
+func (c *Config) applyEnv() error {
+	if v := getEnv("WEBHOOK_ID"); v != "" {
+		c.WebhookIDs = splitList(v)
+	}
+	if v := getEnv("WATCHTOWER_API_KEY"); v != "" {
+		c.APIKey = v
+	}
+	// A mounted secret takes precedence over the plain variable
+	if path := getEnv("WATCHTOWER_API_KEY_FILE"); path != "" {
+		key, err := ReadSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("invalid WATCHTOWER_API_KEY_FILE: %w", err)
+		}
+		c.APIKey = key
+	}
+	if v := getEnv("WATCHTOWER_URL"); v != "" {
+		c.WatchtowerURLs = splitList(v)
+	}
+	if v := getEnv("ROUTES"); v != "" {
+		routes, err := ParseRoutes(v)
+		if err != nil {
+			return fmt.Errorf("invalid ROUTES: %w", err)
+		}
+		c.Routes = routes
+	}
+	if v := getEnv("WATCH_ONLY_FOR_LATEST_TAG"); v != "" {
+		c.WatchOnlyForLatestTag = strings.ToLower(v) == "true"
+	}
+	if v := getEnv("WATCH_TAGS"); v != "" {
+		c.WatchTags = splitList(v)
+	}
+	if v := getEnv("WATCH_TAG_REGEX"); v != "" {
+		c.WatchTagRegex = v
+	}
+	if v := getEnv("WATCH_TAG_SEMVER"); v != "" {
+		c.WatchTagSemver = v
+	}
+	if v := getEnv("DELAY_SECONDS"); v != "" {
+		// An explicit 0 disables the delay
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil && parsed >= 0 {
+			c.DelaySeconds = int(parsed)
+		}
+	}
+	if v := getEnv("DELAY_OVERRIDES"); v != "" {
+		overrides, err := ParseDelayOverrides(v)
+		if err != nil {
+			return fmt.Errorf("invalid DELAY_OVERRIDES: %w", err)
+		}
+		c.DelayOverrides = overrides
+	}
+	return nil
+}
+
+// Validate checks that required settings are present.
+func (c *Config) Validate() error {
+	if len(c.WebhookIDs) == 0 {
+		return errors.New("webhook_ids (WEBHOOK_ID) is required")
+	}
+	if c.APIKey == "" {
+		return errors.New("api_key (WATCHTOWER_API_KEY or WATCHTOWER_API_KEY_FILE) is required")
+	}
+	if c.DelaySeconds < 0 {
+		return errors.New("delay_seconds (DELAY_SECONDS) must not be negative")
+	}
+	for repo, seconds := range c.DelayOverrides {
+		if seconds < 0 {
+			return fmt.Errorf("delay_overrides (DELAY_OVERRIDES) for %s must not be negative", repo)
+		}
+	}
+	for repo, route := range c.Routes {
+		if repo == "" || route.Target == "" {
+			return fmt.Errorf("routes (ROUTES) has an empty entry %q=%q", repo, route.Target)
+		}
+	}
+	if err := validatePatterns("repository", slices.Collect(maps.Keys(c.Routes))); err != nil {
+		return fmt.Errorf("routes (ROUTES): %w", err)
+	}
+	keys := make(map[string]string)
+	for repo, route := range c.Routes {
+		if key, ok := keys[route.Target]; ok && route.APIKey != "" && key != "" && key != route.APIKey {
+			return fmt.Errorf("routes (ROUTES) define different API keys for %s (repository %s)", route.Target, repo)
+		}
+		if route.APIKey != "" {
+			keys[route.Target] = route.APIKey
+		}
+	}
+	return nil
+}
+
+// ParseDelayOverrides parses a comma-separated list of repo=seconds pairs.
+func ParseDelayOverrides(value string) (map[string]int, error) {
+	overrides := make(map[string]int)
+	for _, entry := range splitList(value) {
+		repo, seconds, ok := strings.Cut(entry, "=")
+		repo = strings.TrimSpace(repo)
+		parsed, err := strconv.Atoi(strings.TrimSpace(seconds))
+		if !ok || repo == "" || err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid delay override %q, expected repo=seconds", entry)
+		}
+		overrides[repo] = parsed
+	}
+	return overrides, nil
+}
+
+// DelayFor returns the delay for a repository, falling back to the global
+// delay when it has no override.
+func (c *Config) DelayFor(repo string) int {
+	if seconds, ok := c.DelayOverrides[repo]; ok {
+		return seconds
+	}
+	return c.DelaySeconds
+}
+
+// ParsePort validates a PORT value, defaulting to 3000 when empty.
+func ParsePort(value string) (string, error) {
+	if value == "" {
+		return "3000", nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return "", fmt.Errorf("port %q must be a number between 1 and 65535", value)
+	}
+	return strconv.Itoa(port), nil
+}
+
+// ReadSecretFile reads a secret from a file such as a Docker or Kubernetes
+// secret, dropping the trailing newline.
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// getEnv returns the sanitized value of an environment variable.
+func getEnv(key string) string {
+	return sanitizeEnv(os.Getenv(key))
+}
+
+// sanitizeEnv trims surrounding whitespace and one pair of matching quotes,
+// which are easily left in values by Docker Compose files.
+func sanitizeEnv(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = strings.TrimSpace(value[1 : len(value)-1])
+	}
+	return value
+}
+
+// splitList splits a comma-separated value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = sanitizeEnv(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// redactSecret hides a secret value while showing whether it is set.
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "REDACTED"
+}
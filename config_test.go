@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyEnvZeroDelay(t *testing.T) {
+	t.Setenv("DELAY_SECONDS", "0")
+	cfg := &Config{DelaySeconds: 20}
+	if err := cfg.applyEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DelaySeconds != 0 {
+		t.Errorf("DelaySeconds = %d, want 0", cfg.DelaySeconds)
+	}
+	if got := cfg.DelayFor("myorg/app"); got != 0 {
+		t.Errorf("DelayFor() = %d, want 0", got)
+	}
+}
+
+func TestApplyEnvInvalidDelayKeepsDefault(t *testing.T) {
+	t.Setenv("DELAY_SECONDS", "-5")
+	cfg := &Config{DelaySeconds: 20}
+	if err := cfg.applyEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DelaySeconds != 20 {
+		t.Errorf("DelaySeconds = %d, want the default 20", cfg.DelaySeconds)
+	}
+}
+
+func TestWaitForForwardZeroDelay(t *testing.T) {
+	// A cancelled context proves that no sleep happens: a sleep would
+	// return the cancellation error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := waitForForward(ctx, slog.Default(), time.Now()); err != nil {
+		t.Fatalf("waitForForward() = %v, want an immediate forward", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("waitForForward() took %v, want no delay", elapsed)
+	}
+}
+
+func TestWaitForForwardDelay(t *testing.T) {
+	start := time.Now()
+	if err := waitForForward(context.Background(), slog.Default(), start.Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("waitForForward() returned after %v, want at least 50ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitForForward(ctx, slog.Default(), time.Now().Add(time.Hour)); err == nil {
+		t.Error("waitForForward() = nil with a cancelled context, want an error")
+	}
+}
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "3000", false},
+		{"8080", "8080", false},
+		{"1", "1", false},
+		{"65535", "65535", false},
+		{"http", "", true},
+		{"0", "", true},
+		{"65536", "", true},
+		{"-1", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePort(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePort(%q) = %q, %v, want %q (error: %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("WATCHTOWER_SECRET", "s3cret")
+	t.Setenv("WATCHTOWER_HOST", "watchtower")
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"${WATCHTOWER_SECRET}", "s3cret"},
+		{"$WATCHTOWER_SECRET", "s3cret"},
+		{"http://${WATCHTOWER_HOST}:8080", "http://watchtower:8080"},
+		{"pa$$word", "pa$word"},
+		{"$${WATCHTOWER_SECRET}", "${WATCHTOWER_SECRET}"},
+		{"${UNSET_WATCHTOWER_PROXY_VARIABLE}", ""},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := expandEnv(tt.value); got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConfigExpandEnv(t *testing.T) {
+	t.Setenv("WATCHTOWER_SECRET", "s3cret")
+	cfg := &Config{
+		APIKey:         "${WATCHTOWER_SECRET}",
+		WatchtowerURLs: []string{"http://watchtower:8080"},
+		Routes:         Routes{"myorg/api": {Target: "http://watchtower-api:8080", APIKey: "${WATCHTOWER_SECRET}"}},
+	}
+	cfg.expandEnv()
+	if cfg.APIKey != "s3cret" {
+		t.Errorf("APIKey = %q, want %q", cfg.APIKey, "s3cret")
+	}
+	if key := cfg.Routes["myorg/api"].APIKey; key != "s3cret" {
+		t.Errorf("route APIKey = %q, want %q", key, "s3cret")
+	}
+}
+
+func TestSanitizeEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"  value  ", "value"},
+		{"\tvalue\n", "value"},
+		{`"value"`, "value"},
+		{`'value'`, "value"},
+		{`" value "`, "value"},
+		{`"value'`, `"value'`},
+		{`'value"`, `'value"`},
+		{`"`, `"`},
+		{`'`, `'`},
+		{`""`, ""},
+		{`"a "quoted" value"`, `a "quoted" value`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeEnv(tt.value); got != tt.want {
+			t.Errorf("sanitizeEnv(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSplitListSanitizesItems(t *testing.T) {
+	got := splitList(` "a" , 'b',, c `)
+	if want := []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("splitList() = %q, want %q", got, want)
+	}
+}
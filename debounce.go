@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Debouncer coalesces rapid triggers for the same key so that only the last
+// one fires after a quiet period.
+type Debouncer struct {
+	Window time.Duration
+	// MaxDelay bounds how long a key can be held by repeated resets since its
+	// first trigger. Zero means unbounded.
+	MaxDelay time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*debounceEntry
+}
+
+type debounceEntry struct {
+	timer     *time.Timer
+	firstSeen time.Time
+}
+
+// NewDebouncer creates a Debouncer with the given quiet period.
+func NewDebouncer(window time.Duration) *Debouncer {
+	return &Debouncer{
+		Window:  window,
+		pending: make(map[string]*debounceEntry),
+	}
+}
+
+// Trigger schedules fn to run once the key has been quiet for the window,
+// cancelling any previously scheduled call for the same key. fn receives the
+// time of the first trigger coalesced into the call. Trigger reports whether
+// a previously scheduled call was cancelled.
+func (d *Debouncer) Trigger(key string, fn func(firstSeen time.Time)) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	replaced := false
+	entry := &debounceEntry{firstSeen: time.Now()}
+	if existing, ok := d.pending[key]; ok {
+		replaced = existing.timer.Stop()
+		if replaced {
+			entry.firstSeen = existing.firstSeen
+		}
+		slog.Debug("Debounce timer reset", "key", key)
+	}
+
+	wait := d.Window
+	forced := false
+	if d.MaxDelay > 0 {
+		if remaining := time.Until(entry.firstSeen.Add(d.MaxDelay)); remaining < wait {
+			wait = max(remaining, 0)
+			forced = true
+		}
+	}
+
+	entry.timer = time.AfterFunc(wait, func() {
+		d.mu.Lock()
+		if d.pending[key] == entry {
+			delete(d.pending, key)
+		}
+		d.mu.Unlock()
+
+		if forced {
+			slog.Info("Maximum delay reached - forcing debounced forward", "key", key, "first_seen", entry.firstSeen, "max_delay", d.MaxDelay)
+		} else {
+			slog.Debug("Debounce window elapsed", "key", key)
+		}
+		fn(entry.firstSeen)
+	})
+	d.pending[key] = entry
+	return replaced
+}
@@ -0,0 +1,34 @@
+package main
+
+import "sync"
+
+// DigestTracker remembers the last image digest forwarded per repository and
+// tag.
+type DigestTracker struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+// NewDigestTracker creates an empty DigestTracker.
+func NewDigestTracker() *DigestTracker {
+	return &DigestTracker{last: make(map[string]string)}
+}
+
+// Unchanged reports whether digest equals the last digest recorded for key.
+func (t *DigestTracker) Unchanged(key, digest string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.last[key]
+	return ok && last == digest
+}
+
+// Record records digest as the last one forwarded for key. It is only called
+// once a forward succeeded, so a failed forward is retried by the next
+// webhook carrying the same digest.
+func (t *DigestTracker) Record(key, digest string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.last[key] = digest
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestDigestTracker(t *testing.T) {
+	tracker := NewDigestTracker()
+	if tracker.Unchanged("app:latest", "sha256:a") {
+		t.Error("Unchanged() = true before any forward")
+	}
+	// A failed forward doesn't record the digest, so the next webhook is forwarded
+	if tracker.Unchanged("app:latest", "sha256:a") {
+		t.Error("Unchanged() = true for a digest that was never forwarded")
+	}
+
+	tracker.Record("app:latest", "sha256:a")
+	if !tracker.Unchanged("app:latest", "sha256:a") {
+		t.Error("Unchanged() = false for the recorded digest")
+	}
+	if tracker.Unchanged("app:latest", "sha256:b") {
+		t.Error("Unchanged() = true for a new digest")
+	}
+	if tracker.Unchanged("app:edge", "sha256:a") {
+		t.Error("Unchanged() = true for another tag")
+	}
+}
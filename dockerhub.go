@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Docker Hub callback states.
+const (
+	DockerHubStateSuccess = "success"
+	DockerHubStateFailure = "failure"
+)
+
+// dockerHubCallbackHosts are the hosts Docker Hub sends callback URLs on.
+var dockerHubCallbackHosts = []string{"hub.docker.com", "registry.hub.docker.com"}
+
+// ValidDockerHubCallback reports whether callbackURL is an HTTPS URL on a
+// Docker Hub host, so a forged payload can't make the proxy post to
+// arbitrary URLs.
+func ValidDockerHubCallback(callbackURL string) bool {
+	u, err := url.Parse(callbackURL)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Port() != "" {
+		return false
+	}
+	return slices.Contains(dockerHubCallbackHosts, strings.ToLower(u.Hostname()))
+}
+
+// SendDockerHubCallback reports the outcome of a forward to the callback_url
+// of a Docker Hub webhook in the background. The callback is best-effort and
+// failures are only logged.
+func SendDockerHubCallback(client *http.Client, callbackURL string, success bool, logger *slog.Logger) {
+	if !ValidDockerHubCallback(callbackURL) {
+		logger.Warn("Ignoring Docker Hub callback_url outside Docker Hub", "url", callbackURL)
+		return
+	}
+
+	state := DockerHubStateSuccess
+	description := "Watchtower update triggered"
+	if !success {
+		state = DockerHubStateFailure
+		description = "Watchtower update failed"
+	}
+
+	go func() {
+		body, _ := json.Marshal(map[string]string{
+			"state":       state,
+			"description": description,
+			"context":     "watchtower-proxy",
+		})
+		resp, err := client.Post(callbackURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			logger.Warn("Failed to send Docker Hub callback", "error", err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			logger.Warn("Docker Hub callback rejected", "status", resp.StatusCode)
+			return
+		}
+		logger.Debug("Docker Hub callback sent", "state", state)
+	}()
+}
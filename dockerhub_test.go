@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestValidDockerHubCallback(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://registry.hub.docker.com/u/myorg/app/hook/2141b5bi5i5b02bec211i4eeih0242eg11000a/", true},
+		{"https://hub.docker.com/callback", true},
+		{"http://registry.hub.docker.com/u/myorg/app/hook/abc/", false},
+		{"https://attacker.example.com/u/myorg/app/hook/abc/", false},
+		{"https://registry.hub.docker.com.attacker.example.com/", false},
+		{"https://registry.hub.docker.com:8443/", false},
+		{"https://user@registry.hub.docker.com/", false},
+		{"::invalid", false},
+	}
+	for _, tt := range tests {
+		if got := ValidDockerHubCallback(tt.url); got != tt.want {
+			t.Errorf("ValidDockerHubCallback(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Forwarder sends webhook payloads to Watchtower instances.
+type Forwarder struct {
+	Client *http.Client
+	APIKey string
+	// Routes provide per-target API keys overriding APIKey.
+	Routes       Routes
+	BasicUser    string
+	BasicPass    string
+	Path         string
+	Method       string
+	MaxRetries   int
+	RetryBackoff time.Duration
+	DryRun       bool
+	// BodyTemplate renders the forwarded body instead of the raw webhook.
+	BodyTemplate *template.Template
+	ContentType  string
+	UserAgent    string
+	// Fallback receives the forward when a target fails after all retries.
+	Fallback string
+	// Headers are added to every forward, overriding the automatic ones.
+	Headers http.Header
+	// FormatPaths override Path for webhooks of a payload format.
+	FormatPaths map[string]string
+	// Success decides which responses count as successful, 2xx by default.
+	Success *SuccessCriteria
+	// AuthScheme prefixes the API key in the Authorization header. The key
+	// is sent alone when empty.
+	AuthScheme string
+	// Health skips targets after repeated failures when set.
+	Health *TargetHealth
+	// LogTemplate renders the forwarded and failed messages when set.
+	LogTemplate *LogTemplate
+}
+
+// WatchtowerAuthorizationHeader carries the Watchtower Authorization value
+// when basic credentials for a gate take the Authorization header.
+const WatchtowerAuthorizationHeader = "X-Watchtower-Authorization"
+
+// ParseAuthScheme validates an AUTH_SCHEME value, which must be a single
+// token such as Bearer or Token, or empty to send the raw key.
+func ParseAuthScheme(value string) (string, error) {
+	for _, c := range value {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", c) {
+			return "", fmt.Errorf("scheme %q must be a single token such as Bearer", value)
+		}
+	}
+	return value, nil
+}
+
+// authorization returns the Authorization header value for key.
+func (f *Forwarder) authorization(key string) string {
+	if f.AuthScheme == "" {
+		return key
+	}
+	return f.AuthScheme + " " + key
+}
+
+// ParseFormatPaths parses a comma-separated list of format=path pairs.
+func ParseFormatPaths(value string) (map[string]string, error) {
+	paths := make(map[string]string)
+	for _, entry := range splitList(value) {
+		format, path, ok := strings.Cut(entry, "=")
+		format = strings.ToLower(strings.TrimSpace(format))
+		path = strings.TrimSpace(path)
+		if !ok || format == "" || path == "" {
+			return nil, fmt.Errorf("invalid path override %q, expected format=path", entry)
+		}
+		if _, err := NewPayloadParser(format); err != nil {
+			return nil, err
+		}
+		paths[format] = path
+	}
+	return paths, nil
+}
+
+// pathFor returns the Watchtower endpoint path for the webhook's format.
+func (f *Forwarder) pathFor(hook *Webhook) string {
+	if path, ok := f.FormatPaths[hook.Format]; ok {
+		return path
+	}
+	return f.Path
+}
+
+// ParseForwardHeaders parses a comma-separated list of "Key: Value" headers.
+func ParseForwardHeaders(value string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected Key: Value", name)
+		}
+		headers.Set(name, strings.TrimSpace(val))
+	}
+	return headers, nil
+}
+
+// ParseForwardMethod validates a FORWARD_METHOD value, defaulting to POST.
+func ParseForwardMethod(value string) (string, error) {
+	method := strings.ToUpper(value)
+	switch method {
+	case "":
+		return http.MethodPost, nil
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unsupported forward method %q, expected GET, POST, PUT or PATCH", value)
+	}
+}
+
+// ForwardBodyData is the data available to FORWARD_BODY_TEMPLATE.
+type ForwardBodyData struct {
+	Repo      string
+	Tag       string
+	WebhookID string
+	RequestID string
+}
+
+// ParseBodyTemplate parses a FORWARD_BODY_TEMPLATE value and checks that it
+// renders with sample data, so mistakes are reported at startup.
+func ParseBodyTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("body").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := ForwardBodyData{Repo: "myorg/app", Tag: "latest", WebhookID: "id", RequestID: "request"}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// body returns the request body forwarded for hook.
+func (f *Forwarder) body(hook *Webhook) ([]byte, error) {
+	body := hook.Body
+	if f.BodyTemplate != nil {
+		var buf bytes.Buffer
+		data := ForwardBodyData{Repo: hook.Repository, Tag: hook.Tag, WebhookID: hook.ID, RequestID: hook.RequestID}
+		if err := f.BodyTemplate.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+	}
+	if hook.Gzip {
+		return gzipBytes(body)
+	}
+	return body, nil
+}
+
+// NewForwardClient returns the HTTP client shared by all forwards so that
+// connections to Watchtower are pooled and reused.
+func NewForwardClient(timeout time.Duration, proxy *url.URL) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 10
+	transport.IdleConnTimeout = 90 * time.Second
+	// The default transport honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// ParseProxyURL validates a FORWARD_PROXY_URL value. HTTP, HTTPS and SOCKS5
+// proxies are supported.
+func ParseProxyURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https, socks5 or socks5h", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", u.Redacted())
+	}
+	return u, nil
+}
+
+// ForwardResult describes the final outcome of forwarding to a target.
+type ForwardResult struct {
+	Target     string
+	StatusCode int
+	Body       []byte
+	Err        error
+	DryRun     bool
+	// Coalesced is set when the forward was skipped because the target was
+	// updated recently.
+	Coalesced bool
+	// Category classifies the Watchtower response, see ClassifyResponse.
+	Category string
+	// ContentType is the Content-Type of the Watchtower response.
+	ContentType string
+}
+
+// Success reports whether Watchtower accepted the forward.
+func (r *ForwardResult) Success() bool {
+	return r.Err == nil && (r.DryRun || r.Category == ResponseSuccess)
+}
+
+// Forward posts the webhook body to a single Watchtower target and logs the
+// outcome. Transport errors and 5xx responses are retried with exponential
+// backoff. Failures are logged and never affect other targets.
+func (f *Forwarder) Forward(ctx context.Context, hook *Webhook, target string) *ForwardResult {
+	logger := hook.Logger().With("target", target)
+	backoff := f.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		logger.Debug("Forward attempt to Watchtower", "attempt", attempt, "max_attempts", f.MaxRetries+1)
+
+		result := f.send(ctx, logger, hook, target)
+		if result.DryRun {
+			return result
+		}
+		if ctx.Err() != nil {
+			logCancelled(ctx, logger)
+			result.Err = ctx.Err()
+			return result
+		}
+		retryable := !result.Success() && (result.Err != nil || result.StatusCode >= 500)
+		if !retryable {
+			return result
+		}
+		if attempt > f.MaxRetries {
+			logger.Error("Webhook could not be forwarded to Watchtower", "attempts", attempt)
+			return result
+		}
+
+		// Exponential backoff with up to 50% jitter
+		wait := backoff + rand.N(backoff/2+1)
+		logger.Debug("Forward attempt failed - retrying", "attempt", attempt, "wait", wait)
+		if err := sleepContext(ctx, wait); err != nil {
+			logCancelled(ctx, logger)
+			result.Err = err
+			return result
+		}
+		backoff *= 2
+	}
+}
+
+// ForwardWithFallback forwards hook to target, trying the fallback target when
+// it fails after all retries. The result is the one of the last target tried.
+func (f *Forwarder) ForwardWithFallback(ctx context.Context, hook *Webhook, target string) *ForwardResult {
+	result := f.forwardIfHealthy(ctx, hook, target)
+	if result.Success() || f.Fallback == "" || f.Fallback == target || ctx.Err() != nil {
+		return result
+	}
+
+	logger := hook.Logger().With("target", target, "fallback", f.Fallback)
+	logger.Warn("Forward to primary Watchtower failed - trying fallback")
+	result = f.forwardIfHealthy(ctx, hook, f.Fallback)
+	if result.Success() {
+		logger.Info("Webhook forwarded to fallback Watchtower")
+	} else {
+		logger.Error("Forward to fallback Watchtower failed too")
+	}
+	return result
+}
+
+// forwardIfHealthy forwards to target unless it is marked unhealthy, and
+// records the outcome in Health.
+func (f *Forwarder) forwardIfHealthy(ctx context.Context, hook *Webhook, target string) *ForwardResult {
+	if !f.Health.Healthy(target) {
+		hook.Logger().Warn("Forward skipped - target is marked unhealthy", "target", target)
+		return &ForwardResult{Target: target, Err: ErrTargetUnhealthy}
+	}
+	result := f.Forward(ctx, hook, target)
+	// A cancelled forward says nothing about the target
+	if ctx.Err() == nil {
+		f.Health.Record(target, result)
+	}
+	return result
+}
+
+// TargetResult is the JSON summary of a synchronous forward to one target.
+type TargetResult struct {
+	Target    string `json:"target"`
+	Status    int    `json:"status,omitempty"`
+	Body      string `json:"body,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Category  string `json:"category,omitempty"`
+	Coalesced bool   `json:"coalesced,omitempty"`
+}
+
+// Summarize converts forward results to their JSON summary and reports
+// whether every forward that was attempted succeeded.
+func Summarize(results []*ForwardResult) ([]TargetResult, bool) {
+	summary := make([]TargetResult, 0, len(results))
+	ok := true
+	for _, result := range results {
+		tr := TargetResult{Target: result.Target, Status: result.StatusCode, Body: string(result.Body), Category: result.Category, Coalesced: result.Coalesced}
+		if result.Err != nil {
+			tr.Error = result.Err.Error()
+		}
+		if !result.Coalesced && !result.Success() {
+			ok = false
+		}
+		summary = append(summary, tr)
+	}
+	return summary, ok
+}
+
+// PassthroughResult picks the response relayed to the caller in SYNC_FORWARD
+// passthrough mode: the first failed forward, otherwise the first forward
+// that reached Watchtower. It returns nil when every forward was coalesced.
+func PassthroughResult(results []*ForwardResult) *ForwardResult {
+	var first *ForwardResult
+	for _, result := range results {
+		if result.Coalesced {
+			continue
+		}
+		if !result.Success() {
+			return result
+		}
+		if first == nil {
+			first = result
+		}
+	}
+	return first
+}
+
+// ForwardAll forwards hook to every target in turn and reports whether all
+// of them succeeded.
+func (f *Forwarder) ForwardAll(ctx context.Context, hook *Webhook, targets []string) ([]TargetResult, bool) {
+	var results []*ForwardResult
+	for _, target := range targets {
+		results = append(results, f.ForwardWithFallback(ctx, hook, target))
+	}
+	countForward(hook, results)
+	return Summarize(results)
+}
+
+// countForward records whether a webhook was forwarded to every target, once
+// retries and fallbacks are done. Webhooks whose forwards were all coalesced
+// or dry runs are not counted.
+func countForward(hook *Webhook, results []*ForwardResult) {
+	attempted := false
+	for _, result := range results {
+		if !result.Coalesced && !result.DryRun {
+			attempted = true
+		}
+	}
+	if !attempted {
+		return
+	}
+	if _, ok := Summarize(results); ok {
+		countOutcome(hook.Repository, OutcomeForwarded)
+	} else {
+		countOutcome(hook.Repository, OutcomeFailed)
+	}
+}
+
+// send performs a single forward attempt.
+func (f *Forwarder) send(ctx context.Context, logger *slog.Logger, hook *Webhook, target string) *ForwardResult {
+	result := &ForwardResult{Target: target}
+
+	ctx, span := tracer.Start(ctx, "watchtower.request", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("watchtower.target", target)))
+	defer span.End()
+
+	// Build the full Watchtower URL
+	watchtowerFullURL := joinURL(target, f.pathFor(hook))
+	logger.Debug("Forwarding to Watchtower endpoint", "url", watchtowerFullURL)
+
+	body, err := f.body(hook)
+	if err != nil {
+		logger.Error("Failed to render forward body template", "error", err)
+		result.Err = err
+		return result
+	}
+
+	// GET requests carry no body
+	var reqBody io.Reader
+	if f.Method != http.MethodGet {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, f.Method, watchtowerFullURL, reqBody)
+	if err != nil {
+		logger.Error("Failed to create request", "error", err)
+		result.Err = err
+		return result
+	}
+
+	// Add authorization header
+	req.Header.Set("Authorization", f.authorization(f.Routes.APIKeyFor(target, f.APIKey)))
+	req.Header.Set("Content-Type", "application/json")
+	logger.Debug("Added Authorization header and Content-Type")
+
+	// Basic credentials for a gate in front of Watchtower. They share the
+	// Authorization header, so the Watchtower token moves to its own header
+	// for the gate to restore instead of being dropped.
+	if f.BasicUser != "" {
+		req.Header.Set(WatchtowerAuthorizationHeader, req.Header.Get("Authorization"))
+		req.SetBasicAuth(f.BasicUser, f.BasicPass)
+		logger.Debug("Added basic auth credentials", "token_header", WatchtowerAuthorizationHeader)
+	}
+
+	// Forward original request headers
+	for name, values := range hook.Headers {
+		req.Header[name] = values
+	}
+	if f.ContentType != "" {
+		req.Header.Set("Content-Type", f.ContentType)
+	}
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	if hook.RequestID != "" {
+		req.Header.Set(RequestIDHeader, hook.RequestID)
+	}
+	for name, values := range f.Headers {
+		req.Header[name] = values
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	if f.DryRun {
+		logger.Info("DRY RUN - request not sent to Watchtower", "method", req.Method, "url", watchtowerFullURL, "headers", redactHeaders(req.Header, slices.Collect(maps.Keys(f.Headers))...))
+		result.StatusCode = http.StatusOK
+		result.DryRun = true
+		return result
+	}
+
+	logger.Debug("Executing request to Watchtower")
+
+	// Execute request
+	start := time.Now()
+	resp, err := f.Client.Do(req)
+	elapsed := time.Since(start)
+	logData := LogData{Repo: hook.Repository, Tag: hook.Tag, WebhookID: hook.ID, RequestID: hook.RequestID, Target: target, Duration: elapsed}
+	if err != nil {
+		forwardDuration.WithLabelValues(hook.Repository, OutcomeFailed).Observe(elapsed.Seconds())
+		result.Category = ResponseUnreachable
+		countResponse(result.Category)
+		logData.Event, logData.Error = LogEventFailed, err.Error()
+		logger.Error(f.LogTemplate.Message(logData, "Failed to forward request to Watchtower"), "error", err, "duration", elapsed)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.ContentType = resp.Header.Get("Content-Type")
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	// Log response details for debugging
+	logger.Debug("Watchtower response", "status", resp.StatusCode, "headers", resp.Header)
+
+	// Read response body for logging
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("Failed to read response body", "error", err)
+	} else {
+		logger.Debug("Watchtower response body", "body", string(respBody))
+		result.Body = respBody
+	}
+
+	// Classify the response and log guidance for failures
+	result.Category = f.Success.Classify(resp.StatusCode, respBody)
+	countResponse(result.Category)
+	logResponseGuidance(logger, result.Category, resp.StatusCode, watchtowerFullURL)
+
+	outcome := OutcomeForwarded
+	if !result.Success() {
+		outcome = OutcomeFailed
+	}
+	forwardDuration.WithLabelValues(hook.Repository, outcome).Observe(elapsed.Seconds())
+
+	bytesSent := 0
+	if reqBody != nil {
+		bytesSent = len(body)
+	}
+	logData.Status = resp.StatusCode
+	if result.Success() {
+		logData.Event = LogEventForwarded
+		logger.Info(f.LogTemplate.Message(logData, "Webhook forwarded to Watchtower successfully"), "status", resp.StatusCode, "bytes_sent", bytesSent, "bytes_received", len(respBody), "duration", elapsed)
+	} else {
+		logData.Event = LogEventFailed
+		logger.Warn(f.LogTemplate.Message(logData, "Webhook forwarded but got an unsuccessful response"), "status", resp.StatusCode, "category", result.Category, "bytes_sent", bytesSent, "bytes_received", len(respBody), "duration", elapsed)
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return result
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitForForward sleeps until forwardAt or until ctx is done. It returns at
+// once without sleeping when forwardAt has passed, e.g. with DELAY_SECONDS=0.
+func waitForForward(ctx context.Context, logger *slog.Logger, forwardAt time.Time) error {
+	delay := time.Until(forwardAt)
+	if delay <= 0 {
+		return nil
+	}
+	logger.Debug("Starting delay before forwarding webhook", "delay", delay.Round(time.Second))
+	_, span := tracer.Start(ctx, "delay", trace.WithAttributes(attribute.Float64("delay.seconds", delay.Seconds())))
+	defer span.End()
+	if err := sleepContext(ctx, delay); err != nil {
+		return err
+	}
+	logger.Debug("Delay completed - now forwarding webhook to Watchtower")
+	return nil
+}
+
+// recoverForward logs a panic of a forward goroutine instead of crashing the
+// server. It must be deferred directly by the goroutine.
+func recoverForward(logger *slog.Logger) {
+	if rec := recover(); rec != nil {
+		logger.Error("Panic while forwarding webhook", "panic", rec, "stack", string(debug.Stack()))
+	}
+}
+
+// logCancelled logs why a forward was aborted.
+func logCancelled(ctx context.Context, logger *slog.Logger) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Error("Forward cancelled due to timeout")
+	} else {
+		logger.Warn("Forward cancelled due to shutdown")
+	}
+}
+
+// redactHeaders returns a copy of the headers safe for logging, hiding the
+// Authorization header and the additional sensitive headers.
+func redactHeaders(headers http.Header, sensitive ...string) http.Header {
+	redacted := headers.Clone()
+	for _, name := range append([]string{"Authorization", WatchtowerAuthorizationHeader}, sensitive...) {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "REDACTED")
+		}
+	}
+	return redacted
+}
+
+// NormalizeWatchtowerURL validates a Watchtower base URL, defaulting to
+// http:// when no scheme is given and removing trailing slashes.
+func NormalizeWatchtowerURL(raw string) (string, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q in %q, expected http or https", u.Scheme, raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host in %q", raw)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// joinURL appends path to base with exactly one slash between them.
+func joinURL(base, path string) string {
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
+}
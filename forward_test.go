@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForwardSendsTokenWithBasicAuth(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	f := &Forwarder{
+		Client:     server.Client(),
+		APIKey:     "token",
+		AuthScheme: "Bearer",
+		BasicUser:  "gate",
+		BasicPass:  "secret",
+		Path:       "/v1/update",
+		Method:     http.MethodPost,
+	}
+	result := f.Forward(context.Background(), &Webhook{ID: "id", Body: []byte(`{}`)}, server.URL)
+	if !result.Success() {
+		t.Fatalf("Forward() failed: status %d, error %v", result.StatusCode, result.Err)
+	}
+
+	if user, pass, ok := (&http.Request{Header: got}).BasicAuth(); !ok || user != "gate" || pass != "secret" {
+		t.Errorf("basic credentials = %q:%q (%v), want gate:secret", user, pass, ok)
+	}
+	if token := got.Get(WatchtowerAuthorizationHeader); token != "Bearer token" {
+		t.Errorf("%s = %q, want %q", WatchtowerAuthorizationHeader, token, "Bearer token")
+	}
+}
+
+func TestForwardSendsTokenWithoutBasicAuth(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	f := &Forwarder{Client: server.Client(), APIKey: "token", AuthScheme: "Bearer", Path: "/v1/update", Method: http.MethodPost, RetryBackoff: time.Millisecond}
+	f.Forward(context.Background(), &Webhook{ID: "id", Body: []byte(`{}`)}, server.URL)
+
+	if auth := got.Get("Authorization"); auth != "Bearer token" {
+		t.Errorf("Authorization = %q, want %q", auth, "Bearer token")
+	}
+	if token := got.Get(WatchtowerAuthorizationHeader); token != "" {
+		t.Errorf("%s = %q, want it unset", WatchtowerAuthorizationHeader, token)
+	}
+}
+
+func TestRecoverForwardKeepsServerUp(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(NewTextHandler(&logs, slog.LevelInfo))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward without an HTTP client panics in its own goroutine, as
+		// the forwards scheduled by the webhook handler do
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer recoverForward(logger)
+			f := &Forwarder{Path: "/v1/update", Method: http.MethodPost}
+			f.Forward(context.Background(), &Webhook{ID: "id"}, "http://watchtower:8080")
+		}()
+		<-done
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	// An unrecovered panic would crash the test binary before the second
+	// request is served
+	for i := range 2 {
+		resp, err := http.Post(server.URL, "application/json", nil)
+		if err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("request %d: status = %d, want %d", i+1, resp.StatusCode, http.StatusCreated)
+		}
+	}
+	if !strings.Contains(logs.String(), "Panic while forwarding webhook") {
+		t.Errorf("panic was not logged, logs:\n%s", logs.String())
+	}
+}
+
+func TestForwardAllCountsFallbackSuccessOnce(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fallback.Close()
+
+	f := &Forwarder{Client: http.DefaultClient, Fallback: fallback.URL, Path: "/v1/update", Method: http.MethodPost, RetryBackoff: time.Millisecond}
+	before := stats.Snapshot()
+	if _, ok := f.ForwardAll(context.Background(), &Webhook{ID: "id", Body: []byte(`{}`)}, []string{primary.URL}); !ok {
+		t.Fatal("ForwardAll() failed, want the fallback to succeed")
+	}
+	after := stats.Snapshot()
+
+	if got := after.Succeeded - before.Succeeded; got != 1 {
+		t.Errorf("succeeded grew by %d, want 1", got)
+	}
+	if got := after.Failed - before.Failed; got != 0 {
+		t.Errorf("failed grew by %d, want 0", got)
+	}
+}
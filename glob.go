@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"path"
+)
+
+// validatePatterns checks that every pattern is a valid glob. kind names the
+// patterns in the error, e.g. "tag" or "repository".
+func validatePatterns(kind string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePatterns(t *testing.T) {
+	tests := []struct {
+		kind     string
+		patterns []string
+		wantErr  bool
+	}{
+		{"repository", []string{"myorg/*", "*/api", "myorg/app-?"}, false},
+		{"repository", []string{"myorg/[api"}, true},
+		{"tag", []string{"*-rc*", "sha-?????", "v[0-9]*"}, false},
+		{"tag", []string{"v[0-9"}, true},
+	}
+	for _, tt := range tests {
+		err := validatePatterns(tt.kind, tt.patterns)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validatePatterns(%q, %v) = %v, want error %v", tt.kind, tt.patterns, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "invalid "+tt.kind+" pattern") {
+			t.Errorf("validatePatterns(%q, %v) = %v, want the kind in the error", tt.kind, tt.patterns, err)
+		}
+	}
+}
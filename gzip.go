@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Supported values for GZIP_FORWARD.
+const (
+	GzipForwardDecompressed = "decompressed"
+	GzipForwardCompressed   = "compressed"
+)
+
+// ParseGzipForward validates a GZIP_FORWARD value, defaulting to
+// decompressed.
+func ParseGzipForward(value string) (string, error) {
+	switch value = strings.ToLower(value); value {
+	case "":
+		return GzipForwardDecompressed, nil
+	case GzipForwardDecompressed, GzipForwardCompressed:
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported GZIP_FORWARD value %q, expected decompressed or compressed", value)
+	}
+}
+
+// isGzipped reports whether the request body is gzip-encoded.
+func isGzipped(r *http.Request) bool {
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get("Content-Encoding")), "gzip")
+}
+
+// gunzip decompresses body, failing with *http.MaxBytesError when the
+// decompressed content exceeds limit so that small gzip bombs are rejected.
+func gunzip(body []byte, limit int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &http.MaxBytesError{Limit: limit}
+	}
+	return data, nil
+}
+
+// gzipBytes compresses data.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrTargetUnhealthy is the error of a forward skipped because its target is
+// marked unhealthy.
+var ErrTargetUnhealthy = errors.New("target is marked unhealthy - forward skipped")
+
+// TargetHealth counts consecutive failed forwards per target, like a circuit
+// breaker. A target reaching FailureThreshold is marked unhealthy and skipped
+// until a probe sent every ProbeInterval gets an HTTP response from it.
+type TargetHealth struct {
+	FailureThreshold int
+	ProbeInterval    time.Duration
+	Client           *http.Client
+
+	mu      sync.Mutex
+	targets map[string]*targetState
+}
+
+type targetState struct {
+	failures       int
+	unhealthySince time.Time
+	lastError      string
+}
+
+// TargetStatus is the JSON representation of the health of a target.
+type TargetStatus struct {
+	Healthy             bool       `json:"healthy"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	UnhealthySince      *time.Time `json:"unhealthy_since,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// NewTargetHealth creates a TargetHealth with the given threshold and probe
+// interval.
+func NewTargetHealth(failureThreshold int, probeInterval time.Duration) *TargetHealth {
+	return &TargetHealth{
+		FailureThreshold: failureThreshold,
+		ProbeInterval:    probeInterval,
+		Client:           &http.Client{Timeout: 3 * time.Second},
+		targets:          make(map[string]*targetState),
+	}
+}
+
+// Healthy reports whether forwards to target may proceed. A nil TargetHealth
+// considers every target healthy.
+func (h *TargetHealth) Healthy(target string) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.targets[target]
+	return !ok || state.unhealthySince.IsZero()
+}
+
+// Record updates the health of a target with the outcome of a forward.
+// Transport errors and 5xx responses count as failures, like for retries.
+func (h *TargetHealth) Record(target string, result *ForwardResult) {
+	if h == nil || result.DryRun {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.targets[target]
+	if !ok {
+		state = &targetState{}
+		h.targets[target] = state
+	}
+
+	if result.Err == nil && result.StatusCode < 500 {
+		state.failures = 0
+		state.lastError = ""
+		return
+	}
+
+	state.failures++
+	if result.Err != nil {
+		state.lastError = result.Err.Error()
+	} else {
+		state.lastError = http.StatusText(result.StatusCode)
+	}
+	if state.failures >= h.FailureThreshold && state.unhealthySince.IsZero() {
+		state.unhealthySince = time.Now()
+		slog.Warn("Target marked unhealthy - skipping forwards until it answers a probe", "target", target, "consecutive_failures", state.failures, "probe_interval", h.ProbeInterval)
+		go h.probe(target)
+	}
+}
+
+// probe checks an unhealthy target every ProbeInterval until it answers an
+// HTTP request, then marks it healthy again. Any HTTP response counts, since
+// the base URL usually isn't routed.
+func (h *TargetHealth) probe(target string) {
+	ticker := time.NewTicker(h.ProbeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), h.Client.Timeout)
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+		if err != nil {
+			cancel()
+			slog.Error("Failed to create probe request", "target", target, "error", err)
+			continue
+		}
+		resp, err := h.Client.Do(req)
+		cancel()
+		if err != nil {
+			slog.Debug("Unhealthy target still unreachable", "target", target, "error", err)
+			continue
+		}
+		resp.Body.Close()
+
+		h.mu.Lock()
+		state := h.targets[target]
+		downtime := time.Since(state.unhealthySince)
+		state.failures = 0
+		state.unhealthySince = time.Time{}
+		state.lastError = ""
+		h.mu.Unlock()
+
+		slog.Info("Target answered probe - marked healthy again", "target", target, "status", resp.StatusCode, "downtime", downtime.Round(time.Second))
+		return
+	}
+}
+
+// Snapshot returns the health of every target that received a forward.
+func (h *TargetHealth) Snapshot() map[string]TargetStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := make(map[string]TargetStatus, len(h.targets))
+	for target, state := range h.targets {
+		status := TargetStatus{
+			Healthy:             state.unhealthySince.IsZero(),
+			ConsecutiveFailures: state.failures,
+			LastError:           state.lastError,
+		}
+		if !status.Healthy {
+			since := state.unhealthySince
+			status.UnhealthySince = &since
+		}
+		snapshot[target] = status
+	}
+	return snapshot
+}
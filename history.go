@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// HistoryEntry records what happened to a received webhook.
+type HistoryEntry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	WebhookID  string    `json:"webhook_id"`
+	Repository string    `json:"repository"`
+	Tag        string    `json:"tag"`
+	Forwarded  bool      `json:"forwarded"`
+	Reason     string    `json:"reason,omitempty"`
+
+	// webhook and targets are kept for forwarded webhooks so they can be
+	// replayed.
+	webhook *Webhook
+	targets []string
+}
+
+// Replayable reports whether the entry stored the webhook needed to replay it.
+func (e HistoryEntry) Replayable() bool {
+	return e.webhook != nil
+}
+
+// History is a fixed-size ring buffer of the most recent webhooks.
+type History struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int
+	full    bool
+}
+
+// NewHistory creates a History keeping the last size entries.
+func NewHistory(size int) *History {
+	return &History{entries: make([]HistoryEntry, size)}
+}
+
+// Add records an entry, evicting the oldest one when the buffer is full.
+func (h *History) Add(entry HistoryEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Entries returns the recorded entries, newest first.
+func (h *History) Entries() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	count := h.next
+	if h.full {
+		count = len(h.entries)
+	}
+	entries := make([]HistoryEntry, 0, count)
+	for i := 1; i <= count; i++ {
+		entries = append(entries, h.entries[(h.next-i+len(h.entries))%len(h.entries)])
+	}
+	return entries
+}
+
+// Find returns the entry with the given ID.
+func (h *History) Find(id string) (HistoryEntry, bool) {
+	for _, entry := range h.Entries() {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return HistoryEntry{}, false
+}
@@ -0,0 +1,35 @@
+package main
+
+import "sync"
+
+// InFlight tracks the repository and tag pairs with a pending forward, so
+// that identical webhooks arriving meanwhile are coalesced.
+type InFlight struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+// NewInFlight creates an empty InFlight set.
+func NewInFlight() *InFlight {
+	return &InFlight{keys: make(map[string]struct{})}
+}
+
+// Start marks key as in flight and reports false if it already was.
+func (f *InFlight) Start(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.keys[key]; ok {
+		return false
+	}
+	f.keys[key] = struct{}{}
+	return true
+}
+
+// Finish clears key once its forward completed.
+func (f *InFlight) Finish(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.keys, key)
+}
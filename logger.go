@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// SetupLogging installs the default slog logger. LOG_FORMAT=json emits
+// structured JSON lines; any other value keeps the human-readable format.
+// Records below level are discarded.
+func SetupLogging(format string, level slog.Level) {
+	var handler slog.Handler
+	if strings.ToLower(format) == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	} else {
+		handler = NewTextHandler(os.Stderr, level)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// ParseLogLevel parses a LOG_LEVEL value (debug, info, warn or error).
+// An empty value defaults to info.
+func ParseLogLevel(value string) (slog.Level, error) {
+	level := slog.LevelInfo
+	if value == "" {
+		return level, nil
+	}
+	err := level.UnmarshalText([]byte(value))
+	return level, err
+}
+
+// Webhook lifecycle events whose log message LOG_TEMPLATE can render.
+const (
+	LogEventReceived  = "received"
+	LogEventForwarded = "forwarded"
+	LogEventFailed    = "failed"
+)
+
+// LogData is the data available to LOG_TEMPLATE. Status, Duration and Error
+// are only set for forwarded and failed events.
+type LogData struct {
+	Event     string
+	Repo      string
+	Tag       string
+	WebhookID string
+	RequestID string
+	Target    string
+	Status    int
+	Duration  time.Duration
+	Error     string
+}
+
+// LogTemplate renders the messages of the webhook lifecycle events.
+type LogTemplate struct {
+	tmpl *template.Template
+}
+
+// ParseLogTemplate parses a LOG_TEMPLATE value and checks that it renders
+// every event with sample data, so mistakes are reported at startup.
+func ParseLogTemplate(text string) (*LogTemplate, error) {
+	tmpl, err := template.New("log").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range []string{LogEventReceived, LogEventForwarded, LogEventFailed} {
+		sample := LogData{Event: event, Repo: "myorg/app", Tag: "latest", WebhookID: "id", RequestID: "request", Target: "http://watchtower:8080", Status: 200, Duration: time.Second}
+		if err := tmpl.Execute(io.Discard, sample); err != nil {
+			return nil, err
+		}
+	}
+	return &LogTemplate{tmpl: tmpl}, nil
+}
+
+// Message renders the message of an event, returning fallback when no
+// template is set or it fails to render.
+func (t *LogTemplate) Message(data LogData, fallback string) string {
+	if t == nil {
+		return fallback
+	}
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return fallback
+	}
+	return b.String()
+}
+
+// fatal logs an error and terminates the process.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// TextHandler is a slog.Handler producing the classic human-readable lines:
+//
+//	2006/01/02 15:04:05 DEBUG: message key=value
+type TextHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	level slog.Leveler
+	attrs []slog.Attr
+	group string
+}
+
+// NewTextHandler creates a TextHandler writing records at or above level.
+func NewTextHandler(w io.Writer, level slog.Leveler) *TextHandler {
+	return &TextHandler{w: w, mu: &sync.Mutex{}, level: level}
+}
+
+func (h *TextHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *TextHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	b.WriteString(r.Level.String())
+	b.WriteString(": ")
+	b.WriteString(r.Message)
+	for _, a := range h.attrs {
+		writeAttr(&b, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.group, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *TextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(slices.Clone(h.attrs), qualify(h.group, attrs)...)
+	return &clone
+}
+
+func (h *TextHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	if clone.group != "" {
+		name = clone.group + "." + name
+	}
+	clone.group = name
+	return &clone
+}
+
+func qualify(group string, attrs []slog.Attr) []slog.Attr {
+	if group == "" {
+		return attrs
+	}
+	qualified := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		qualified[i] = slog.Attr{Key: group + "." + a.Key, Value: a.Value}
+	}
+	return qualified
+}
+
+func writeAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	key := a.Key
+	if group != "" {
+		key = group + "." + key
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeAttr(b, key, ga)
+		}
+		return
+	}
+
+	var value string
+	switch a.Value.Kind() {
+	case slog.KindTime:
+		value = a.Value.Time().Format(time.RFC3339)
+	default:
+		value = a.Value.String()
+	}
+	if value == "" || strings.ContainsAny(value, " =\"") {
+		value = strconv.Quote(value)
+	}
+	b.WriteByte(' ')
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(value)
+}
@@ -1,227 +1,1577 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type DockerHubPayload struct {
-	PushData struct {
-		Tag string `json:"tag"`
-	} `json:"push_data"`
-	Repository struct {
-		Name     string `json:"name"`
-		RepoName string `json:"repo_name"`
-	} `json:"repository"`
-}
-
 func main() {
 	// Get environment variables
-	webhookID := os.Getenv("WEBHOOK_ID")
-	apiKey := os.Getenv("WATCHTOWER_API_KEY")
-	port := os.Getenv("PORT")
-	watchtowerURL := os.Getenv("WATCHTOWER_URL")
-	watchOnlyForLatestTag := os.Getenv("WATCH_ONLY_FOR_LATEST_TAG")
-	delaySecondsEnv := os.Getenv("DELAY_SECONDS")
-
-	// Convert the watchOnlyForLatestTag to a boolean
-	watchOnly := false
-	if strings.ToLower(watchOnlyForLatestTag) == "true" {
-		watchOnly = true
-		log.Printf("DEBUG: Watch only for latest tag is ENABLED")
-	} else {
-		log.Printf("DEBUG: Watch only for latest tag is DISABLED - all tags will trigger updates")
+	configFile := getEnv("CONFIG_FILE")
+	port := getEnv("PORT")
+	bindAddress := getEnv("BIND_ADDRESS")
+	maxRetriesEnv := getEnv("MAX_RETRIES")
+	debounceSecondsEnv := getEnv("DEBOUNCE_SECONDS")
+	webhookSecret := getEnv("WEBHOOK_SECRET")
+	gitlabToken := getEnv("GITLAB_WEBHOOK_TOKEN")
+	shutdownTimeoutEnv := getEnv("SHUTDOWN_TIMEOUT_SECONDS")
+	payloadFormat := getEnv("PAYLOAD_FORMAT")
+	logFormat := getEnv("LOG_FORMAT")
+	logLevelEnv := getEnv("LOG_LEVEL")
+	tlsCertFile := getEnv("TLS_CERT_FILE")
+	tlsKeyFile := getEnv("TLS_KEY_FILE")
+	forwardTimeoutEnv := getEnv("FORWARD_TIMEOUT_SECONDS")
+	dryRun := strings.ToLower(getEnv("DRY_RUN")) == "true"
+	watchtowerPath := getEnv("WATCHTOWER_PATH")
+	watchtowerPathOverridesEnv := getEnv("WATCHTOWER_PATH_OVERRIDES")
+	rateLimitEnv := getEnv("RATE_LIMIT_PER_MINUTE")
+	minForwardIntervalEnv := getEnv("MIN_FORWARD_INTERVAL_SECONDS")
+	targetFailureThresholdEnv := getEnv("TARGET_FAILURE_THRESHOLD")
+	targetProbeIntervalEnv := getEnv("TARGET_PROBE_INTERVAL_SECONDS")
+	dedupDigest := strings.ToLower(getEnv("DEDUP_DIGEST")) == "true"
+	historySizeEnv := getEnv("HISTORY_SIZE")
+	slackWebhookURL := getEnv("SLACK_WEBHOOK_URL")
+	discordWebhookURL := getEnv("DISCORD_WEBHOOK_URL")
+	notifyOnEnv := getEnv("NOTIFY_ON")
+	telegramBotToken := getEnv("TELEGRAM_BOT_TOKEN")
+	telegramChatID := getEnv("TELEGRAM_CHAT_ID")
+	maxBodyBytesEnv := getEnv("MAX_BODY_BYTES")
+	requestTimeoutEnv := getEnv("FORWARD_REQUEST_TIMEOUT_SECONDS")
+	basicUser := getEnv("WATCHTOWER_BASIC_USER")
+	basicPass := getEnv("WATCHTOWER_BASIC_PASS")
+	allowedCIDRsEnv := getEnv("ALLOWED_CIDRS")
+	trustProxy := strings.ToLower(getEnv("TRUST_PROXY")) == "true"
+	trustedProxiesEnv := getEnv("TRUSTED_PROXIES")
+	persistQueue := strings.ToLower(getEnv("PERSIST_QUEUE")) == "true"
+	queueFile := getEnv("QUEUE_FILE")
+	maxConcurrentEnv := getEnv("MAX_CONCURRENT_FORWARDS")
+	allowedReposEnv := getEnv("ALLOWED_REPOS")
+	deniedReposEnv := getEnv("DENIED_REPOS")
+	otlpEndpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	delayJitterEnv := getEnv("DELAY_JITTER_SECONDS")
+	bodyTemplateEnv := getEnv("FORWARD_BODY_TEMPLATE")
+	logTemplateEnv := getEnv("LOG_TEMPLATE")
+	forwardContentType := getEnv("FORWARD_CONTENT_TYPE")
+	forwardMethodEnv := getEnv("FORWARD_METHOD")
+	responseStatusEnv := getEnv("RESPONSE_STATUS")
+	tagCaseInsensitive := strings.ToLower(getEnv("TAG_MATCH_CASE_INSENSITIVE")) == "true"
+	userAgent := getEnv("FORWARD_USER_AGENT")
+	forwardClientIP := strings.ToLower(getEnv("FORWARD_CLIENT_IP")) == "true"
+	fallbackURL := getEnv("WATCHTOWER_FALLBACK_URL")
+	mirrorURLEnv := getEnv("MIRROR_URL")
+	gzipForwardEnv := getEnv("GZIP_FORWARD")
+	forwardHeadersEnv := getEnv("FORWARD_HEADERS")
+	maxWebhookAgeEnv := getEnv("MAX_WEBHOOK_AGE_SECONDS")
+	dockerhubCallback := strings.ToLower(getEnv("DOCKERHUB_CALLBACK")) == "true"
+	readTimeoutEnv := getEnv("SERVER_READ_TIMEOUT_SECONDS")
+	writeTimeoutEnv := getEnv("SERVER_WRITE_TIMEOUT_SECONDS")
+	idleTimeoutEnv := getEnv("SERVER_IDLE_TIMEOUT_SECONDS")
+	ignoreTagPatterns := splitList(getEnv("IGNORE_TAG_PATTERNS"))
+	basePath := getEnv("BASE_PATH")
+	signatureHeader := getEnv("SIGNATURE_HEADER")
+	signatureAlgoEnv := getEnv("SIGNATURE_ALGO")
+	startupCheck := strings.ToLower(getEnv("STARTUP_CHECK")) == "true"
+	forwardHeaderAllowlist := ParseHeaderAllowlist(getEnv("FORWARD_HEADER_ALLOWLIST"))
+	maxDelayEnv := getEnv("MAX_DELAY_SECONDS")
+	batchWindowEnv := getEnv("BATCH_WINDOW_SECONDS")
+	// An explicitly empty AUTH_SCHEME sends the raw key
+	authSchemeEnv, authSchemeSet := os.LookupEnv("AUTH_SCHEME")
+	successStatusCodesEnv := getEnv("SUCCESS_STATUS_CODES")
+	successBodyRegexEnv := getEnv("SUCCESS_BODY_REGEX")
+	forwardProxyURLEnv := getEnv("FORWARD_PROXY_URL")
+	webhookIDCaseInsensitive := strings.ToLower(getEnv("WEBHOOK_ID_CASE_INSENSITIVE")) == "true"
+	syncForward := strings.ToLower(getEnv("SYNC_FORWARD")) == "true"
+	syncForwardDelay := strings.ToLower(getEnv("SYNC_FORWARD_DELAY")) == "true"
+	syncForwardPassthrough := strings.ToLower(getEnv("SYNC_FORWARD_PASSTHROUGH")) == "true"
+	syncForwardMaxBodyEnv := getEnv("SYNC_FORWARD_MAX_BODY_BYTES")
+
+	logLevel, err := ParseLogLevel(logLevelEnv)
+	SetupLogging(logFormat, logLevel)
+	if err != nil {
+		fatal("Invalid LOG_LEVEL", "value", logLevelEnv, "error", err)
 	}
 
-	// Parse delay seconds (default to 20)
-	delaySeconds := 20
-	if delaySecondsEnv != "" {
-		if parsed, err := strconv.ParseInt(delaySecondsEnv, 10, 64); err == nil && parsed > 0 {
-			delaySeconds = int(parsed)
+	// Load the optional config file, overridden by environment variables
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	if path := getEnv("WEBHOOK_SECRET_FILE"); path != "" {
+		webhookSecret, err = ReadSecretFile(path)
+		if err != nil {
+			fatal("Invalid WEBHOOK_SECRET_FILE", "error", err)
 		}
 	}
-	log.Printf("DEBUG: Delay before forwarding webhook: %d seconds", delaySeconds)
+	if configFile != "" {
+		slog.Info("Loaded configuration file", "path", configFile)
+	}
+	// Tags matching an ignore pattern are skipped regardless of the watch rules
+	if err := validatePatterns("tag", ignoreTagPatterns); err != nil {
+		fatal("Invalid IGNORE_TAG_PATTERNS", "error", err)
+	}
 
-	if watchtowerURL == "" {
-		log.Printf("WATCHTOWER_URL not set, defaulting to localhost:8080")
-		watchtowerURL = "localhost:8080"
+	// Derive the settings that POST /api/reload can replace at runtime
+	initialSettings, err := NewSettings(cfg, tagCaseInsensitive, ignoreTagPatterns)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
 	}
+	var activeSettings atomic.Pointer[Settings]
+	activeSettings.Store(initialSettings)
 
-	if watchtowerURL == "" {
-		log.Printf("WATCHTOWER_URL not set, defaulting to /v1/update")
-		watchtowerURL = "http://localhost:8080"
-	} else {
-		log.Printf("Using custom WATCHTOWER_URL: %s", watchtowerURL)
+	// Parse max retries (default to 3)
+	maxRetries := 3
+	if maxRetriesEnv != "" {
+		if parsed, err := strconv.ParseInt(maxRetriesEnv, 10, 64); err == nil && parsed >= 0 {
+			maxRetries = int(parsed)
+		}
+	}
+	slog.Debug("Max retries for failed forwards", "retries", maxRetries)
+
+	// Parse debounce window (disabled by default)
+	var debouncer *Debouncer
+	if debounceSecondsEnv != "" {
+		if parsed, err := strconv.ParseInt(debounceSecondsEnv, 10, 64); err == nil && parsed > 0 {
+			debouncer = NewDebouncer(time.Duration(parsed) * time.Second)
+			slog.Debug("Debouncing duplicate webhooks per repository and tag", "seconds", parsed)
+		}
+	}
+
+	// Parse the maximum time a webhook can be held by the debounce and the
+	// delay (unlimited by default)
+	var maxDelay time.Duration
+	if maxDelayEnv != "" {
+		if parsed, err := strconv.ParseInt(maxDelayEnv, 10, 64); err == nil && parsed > 0 {
+			maxDelay = time.Duration(parsed) * time.Second
+			slog.Debug("Maximum forward delay", "seconds", parsed)
+		} else {
+			fatal("Invalid MAX_DELAY_SECONDS", "value", maxDelayEnv)
+		}
+	}
+	if debouncer != nil {
+		debouncer.MaxDelay = maxDelay
+	}
+
+	// Parse the window grouping webhooks for the same targets into a single
+	// forward (disabled by default)
+	var batcher *Batcher
+	if batchWindowEnv != "" {
+		if parsed, err := strconv.ParseInt(batchWindowEnv, 10, 64); err == nil && parsed > 0 {
+			batcher = NewBatcher(time.Duration(parsed) * time.Second)
+			slog.Debug("Batching webhooks per target set", "seconds", parsed)
+		} else {
+			fatal("Invalid BATCH_WINDOW_SECONDS", "value", batchWindowEnv)
+		}
+	}
+
+	// Backup Watchtower used when a forward fails (disabled by default)
+	if fallbackURL != "" {
+		normalized, err := NormalizeWatchtowerURL(fallbackURL)
+		if err != nil {
+			fatal("Invalid WATCHTOWER_FALLBACK_URL", "url", fallbackURL, "error", err)
+		}
+		fallbackURL = normalized
+		slog.Info("Using WATCHTOWER_FALLBACK_URL", "url", fallbackURL)
+	}
+
+	// Observability sink receiving a copy of every webhook (disabled by default)
+	var mirrorURL *url.URL
+	if mirrorURLEnv != "" {
+		mirrorURL, err = ParseMirrorURL(mirrorURLEnv)
+		if err != nil {
+			fatal("Invalid MIRROR_URL", "error", err)
+		}
+		slog.Info("Mirroring webhooks", "url", mirrorURL.Redacted())
+	}
+
+	if watchtowerPath == "" {
+		watchtowerPath = "/v1/update"
+	}
+	slog.Debug("Watchtower endpoint path", "path", watchtowerPath)
+
+	// Parse the endpoint paths used for specific payload formats
+	var formatPaths map[string]string
+	if watchtowerPathOverridesEnv != "" {
+		formatPaths, err = ParseFormatPaths(watchtowerPathOverridesEnv)
+		if err != nil {
+			fatal("Invalid WATCHTOWER_PATH_OVERRIDES", "error", err)
+		}
+		slog.Debug("Watchtower endpoint paths per payload format", "paths", formatPaths)
+	}
+
+	// Parse the status acknowledging accepted webhooks (default to 201)
+	responseStatus := http.StatusCreated
+	if responseStatusEnv != "" {
+		parsed, err := strconv.Atoi(responseStatusEnv)
+		if err != nil || parsed < 200 || parsed > 299 {
+			fatal("Invalid RESPONSE_STATUS, expected a 2xx status code", "value", responseStatusEnv)
+		}
+		responseStatus = parsed
+	}
+
+	if userAgent == "" {
+		userAgent = "watchtower-proxy/" + version
+	}
+
+	forwardHeaders, err := ParseForwardHeaders(forwardHeadersEnv)
+	if err != nil {
+		fatal("Invalid FORWARD_HEADERS", "error", err)
+	}
+	if len(forwardHeaders) > 0 {
+		slog.Debug("Adding custom headers to forwards", "headers", strings.Join(slices.Sorted(maps.Keys(forwardHeaders)), ","))
+	}
+
+	forwardMethod, err := ParseForwardMethod(forwardMethodEnv)
+	if err != nil {
+		fatal("Invalid FORWARD_METHOD", "error", err)
+	}
+	slog.Debug("Watchtower request method", "method", forwardMethod)
+
+	port, err = ParsePort(port)
+	if err != nil {
+		fatal("Invalid PORT", "error", err)
+	}
+	if bindAddress == "" {
+		bindAddress = "0.0.0.0" // all interfaces
+	}
+	listenAddress := net.JoinHostPort(bindAddress, port)
+
+	// Normalize the route prefix to /prefix without a trailing slash
+	basePath = strings.Trim(basePath, "/")
+	if basePath != "" {
+		basePath = "/" + basePath
+		slog.Debug("Serving routes under base path", "base_path", basePath)
+	}
+	parser, err := NewPayloadParser(payloadFormat)
+	if err != nil {
+		fatal("Invalid PAYLOAD_FORMAT", "error", err)
+	}
+	if payloadFormat != "" {
+		slog.Debug("Using payload format", "format", payloadFormat)
+	}
+
+	// Parse shutdown timeout (default to 60)
+	shutdownTimeout := 60 * time.Second
+	if shutdownTimeoutEnv != "" {
+		if parsed, err := strconv.ParseInt(shutdownTimeoutEnv, 10, 64); err == nil && parsed > 0 {
+			shutdownTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+
+	// Parse rate limit per webhook ID (disabled by default)
+	var rateLimiter *RateLimiter
+	if rateLimitEnv != "" {
+		if parsed, err := strconv.ParseInt(rateLimitEnv, 10, 64); err == nil && parsed > 0 {
+			rateLimiter = NewRateLimiter(int(parsed))
+			slog.Debug("Rate limiting webhooks per ID", "per_minute", parsed)
+		}
+	}
+
+	// Parse minimum interval between forwards to the same target (disabled by default)
+	var throttle *Throttle
+	if minForwardIntervalEnv != "" {
+		if parsed, err := strconv.ParseInt(minForwardIntervalEnv, 10, 64); err == nil && parsed > 0 {
+			throttle = NewThrottle(time.Duration(parsed) * time.Second)
+			slog.Debug("Minimum interval between forwards per target", "seconds", parsed)
+		}
+	}
+
+	// Skip targets after consecutive failures until they answer a probe
+	// (disabled by default, probing every 30 seconds)
+	var targetHealth *TargetHealth
+	if targetFailureThresholdEnv != "" {
+		threshold, err := strconv.ParseInt(targetFailureThresholdEnv, 10, 32)
+		if err != nil || threshold <= 0 {
+			fatal("Invalid TARGET_FAILURE_THRESHOLD", "value", targetFailureThresholdEnv)
+		}
+		probeInterval := 30 * time.Second
+		if targetProbeIntervalEnv != "" {
+			parsed, err := strconv.ParseInt(targetProbeIntervalEnv, 10, 64)
+			if err != nil || parsed <= 0 {
+				fatal("Invalid TARGET_PROBE_INTERVAL_SECONDS", "value", targetProbeIntervalEnv)
+			}
+			probeInterval = time.Duration(parsed) * time.Second
+		}
+		targetHealth = NewTargetHealth(int(threshold), probeInterval)
+		slog.Debug("Per-target health tracking ENABLED", "failure_threshold", threshold, "probe_interval", probeInterval)
+	}
+
+	// Track image digests to skip unchanged pushes (disabled by default)
+	var digests *DigestTracker
+	if dedupDigest {
+		digests = NewDigestTracker()
+		slog.Debug("Skipping webhooks whose image digest is unchanged")
+	}
+
+	// Restrict which repositories may trigger updates (all by default)
+	repoFilter := &RepoFilter{Allowed: splitList(allowedReposEnv), Denied: splitList(deniedReposEnv)}
+	if err := validatePatterns("repository", append(slices.Clone(repoFilter.Allowed), repoFilter.Denied...)); err != nil {
+		fatal("Invalid ALLOWED_REPOS or DENIED_REPOS", "error", err)
+	}
+	if repoFilter.Enabled() {
+		slog.Debug("Repository filter ENABLED", "allowed", allowedReposEnv, "denied", deniedReposEnv)
+	}
+
+	// Parse the template of the forwarded body (raw webhook body by default)
+	var bodyTemplate *template.Template
+	if bodyTemplateEnv != "" {
+		bodyTemplate, err = ParseBodyTemplate(bodyTemplateEnv)
+		if err != nil {
+			fatal("Invalid FORWARD_BODY_TEMPLATE", "error", err)
+		}
+		slog.Debug("Forwarding templated body", "template", bodyTemplateEnv)
+	}
+
+	// Custom messages for received, forwarded and failed webhooks
+	var logTemplate *LogTemplate
+	if logTemplateEnv != "" {
+		logTemplate, err = ParseLogTemplate(logTemplateEnv)
+		if err != nil {
+			fatal("Invalid LOG_TEMPLATE", "error", err)
+		}
+		slog.Debug("Logging templated messages", "template", logTemplateEnv)
+	}
+
+	// Parse maximum age of webhook timestamps (disabled by default)
+	var maxWebhookAge time.Duration
+	if maxWebhookAgeEnv != "" {
+		if parsed, err := strconv.ParseInt(maxWebhookAgeEnv, 10, 64); err == nil && parsed > 0 {
+			maxWebhookAge = time.Duration(parsed) * time.Second
+			slog.Debug("Rejecting webhooks older than the maximum age", "seconds", parsed)
+		}
+	}
+
+	// The payload only needs parsing when a feature depends on its fields
+	parsePayload := func(settings *Settings) bool {
+		return settings.WatchOnly || len(settings.Routes) > 0 || len(settings.Config.DelayOverrides) > 0 || len(settings.TagFilter.Ignore) > 0 ||
+			debouncer != nil || digests != nil || repoFilter.Enabled() || bodyTemplate != nil || logTemplate != nil || maxWebhookAge > 0 || dockerhubCallback
+	}
+
+	// Parse random jitter added to each forward delay (disabled by default)
+	var delayJitter time.Duration
+	if delayJitterEnv != "" {
+		if parsed, err := strconv.ParseInt(delayJitterEnv, 10, 64); err == nil && parsed > 0 {
+			delayJitter = time.Duration(parsed) * time.Second
+			slog.Debug("Random jitter added to forward delays", "max_seconds", parsed)
+		}
+	}
+
+	// Parse webhook history size (default to 50)
+	historySize := 50
+	if historySizeEnv != "" {
+		if parsed, err := strconv.ParseInt(historySizeEnv, 10, 64); err == nil && parsed > 0 {
+			historySize = int(parsed)
+		}
+	}
+	history := NewHistory(historySize)
+
+	// Configure notifications (disabled unless a webhook URL is set)
+	notifyOn, err := ParseNotifyOn(notifyOnEnv)
+	if err != nil {
+		fatal("Invalid NOTIFY_ON", "error", err)
+	}
+	var notifiers Notifiers
+	if slackWebhookURL != "" {
+		notifiers = append(notifiers, &SlackNotifier{WebhookURL: slackWebhookURL, NotifyOn: notifyOn, Client: newNotifyClient()})
+		slog.Debug("Slack notifications ENABLED", "notify_on", notifyOn)
+	}
+	if discordWebhookURL != "" {
+		notifiers = append(notifiers, &DiscordNotifier{WebhookURL: discordWebhookURL, NotifyOn: notifyOn, Client: newNotifyClient()})
+		slog.Debug("Discord notifications ENABLED", "notify_on", notifyOn)
+	}
+	if (telegramBotToken == "") != (telegramChatID == "") {
+		fatal("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must both be set to enable Telegram notifications")
+	}
+	if telegramBotToken != "" {
+		notifiers = append(notifiers, &TelegramNotifier{BotToken: telegramBotToken, ChatID: telegramChatID, NotifyOn: notifyOn, Client: newNotifyClient()})
+		slog.Debug("Telegram notifications ENABLED", "chat_id", telegramChatID, "notify_on", notifyOn)
+	}
+
+	// Parse maximum request body size (default to 1 MiB)
+	maxBodyBytes := int64(1 << 20)
+	if maxBodyBytesEnv != "" {
+		if parsed, err := strconv.ParseInt(maxBodyBytesEnv, 10, 64); err == nil && parsed > 0 {
+			maxBodyBytes = parsed
+		}
+	}
+
+	// How gzip-encoded webhooks are forwarded (decompressed by default)
+	gzipForward, err := ParseGzipForward(gzipForwardEnv)
+	if err != nil {
+		fatal("Invalid GZIP_FORWARD", "error", err)
+	}
+
+	// Parse timeout of a single request to Watchtower (default to 30)
+	requestTimeout := 30 * time.Second
+	if requestTimeoutEnv != "" {
+		if parsed, err := strconv.ParseInt(requestTimeoutEnv, 10, 64); err == nil && parsed > 0 {
+			requestTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+
+	// Route forwards through an explicit proxy, otherwise through the one
+	// from HTTP_PROXY/HTTPS_PROXY if any
+	var forwardProxy *url.URL
+	if forwardProxyURLEnv != "" {
+		forwardProxy, err = ParseProxyURL(forwardProxyURLEnv)
+		if err != nil {
+			fatal("Invalid FORWARD_PROXY_URL", "error", err)
+		}
+		slog.Info("Forwarding to Watchtower through proxy", "proxy", forwardProxy.Redacted())
+	} else if proxy := cmp.Or(getEnv("HTTPS_PROXY"), getEnv("https_proxy"), getEnv("HTTP_PROXY"), getEnv("http_proxy")); proxy != "" {
+		if u, err := url.Parse(proxy); err == nil {
+			proxy = u.Redacted()
+		}
+		slog.Info("Forwarding to Watchtower through proxy from the environment", "proxy", proxy)
+	}
+
+	// Parse the allowed source networks for webhooks (all allowed by default)
+	allowedNetworks, err := ParseCIDRs(allowedCIDRsEnv)
+	if err != nil {
+		fatal("Invalid ALLOWED_CIDRS", "error", err)
+	}
+	trustedProxies, err := ParseCIDRs(trustedProxiesEnv)
+	if err != nil {
+		fatal("Invalid TRUSTED_PROXIES", "error", err)
+	}
+	allowlist := &IPAllowlist{Networks: allowedNetworks, TrustProxy: trustProxy, TrustedProxies: trustedProxies}
+	if trustProxy && len(trustedProxies) == 0 && len(allowedNetworks) > 0 {
+		slog.Warn("TRUST_PROXY is enabled without TRUSTED_PROXIES - any client able to reach the proxy directly can spoof X-Forwarded-For")
+	}
+	if len(allowedNetworks) > 0 {
+		slog.Debug("Restricting webhooks to source networks", "cidrs", allowedCIDRsEnv, "trust_proxy", trustProxy)
+	}
+
+	// Parse forward timeout covering the delay and all attempts (default to 300)
+	forwardTimeout := 300 * time.Second
+	if forwardTimeoutEnv != "" {
+		if parsed, err := strconv.ParseInt(forwardTimeoutEnv, 10, 64); err == nil && parsed > 0 {
+			forwardTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+	slog.Debug("Forward timeout", "timeout", forwardTimeout)
+
+	// Validate TLS configuration
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		fatal("TLS_CERT_FILE and TLS_KEY_FILE must both be set to enable TLS")
+	}
+	for _, file := range []string{tlsCertFile, tlsKeyFile} {
+		if file == "" {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			fatal("TLS file is not readable", "file", file, "error", err)
+		}
+		f.Close()
+	}
+
+	if gitlabToken != "" {
+		slog.Debug("GitLab webhook token verification is ENABLED", "header", GitLabTokenHeader)
+	}
+	signatureAlgo, err := ParseSignatureAlgo(signatureAlgoEnv)
+	if err != nil {
+		fatal("Invalid SIGNATURE_ALGO", "error", err)
+	}
+	if signatureHeader == "" {
+		signatureHeader = DefaultSignatureHeader(signatureAlgo)
+	}
+	if webhookSecret != "" {
+		slog.Debug("Webhook signature verification is ENABLED", "header", signatureHeader, "algorithm", signatureAlgo)
+	}
+
+	// Limit the number of concurrent forwards (unlimited by default)
+	var forwardSlots chan struct{}
+	if maxConcurrentEnv != "" {
+		if parsed, err := strconv.ParseInt(maxConcurrentEnv, 10, 64); err == nil && parsed > 0 {
+			forwardSlots = make(chan struct{}, parsed)
+			slog.Debug("Maximum concurrent forwards", "limit", parsed)
+		}
+	}
+
+	// Parse the size cap of a Watchtower body relayed in passthrough mode
+	// (default to 64 KiB)
+	syncForwardMaxBody := 64 << 10
+	if syncForwardMaxBodyEnv != "" {
+		parsed, err := strconv.ParseInt(syncForwardMaxBodyEnv, 10, 32)
+		if err != nil || parsed <= 0 {
+			fatal("Invalid SYNC_FORWARD_MAX_BODY_BYTES", "value", syncForwardMaxBodyEnv)
+		}
+		syncForwardMaxBody = int(parsed)
+	}
+
+	if syncForward {
+		slog.Debug("Synchronous forwarding ENABLED", "apply_delay", syncForwardDelay, "passthrough", syncForwardPassthrough, "max_body_bytes", syncForwardMaxBody)
+	}
+
+	// Parse server timeouts guarding against slow clients. Reading a request,
+	// including its body, defaults to 30 seconds and keep-alive connections
+	// are closed after 120 idle seconds.
+	readTimeout := 30 * time.Second
+	if readTimeoutEnv != "" {
+		if parsed, err := strconv.ParseInt(readTimeoutEnv, 10, 64); err == nil && parsed > 0 {
+			readTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+	idleTimeout := 120 * time.Second
+	if idleTimeoutEnv != "" {
+		if parsed, err := strconv.ParseInt(idleTimeoutEnv, 10, 64); err == nil && parsed > 0 {
+			idleTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+	// Writing the response defaults to 30 seconds, extended in SYNC_FORWARD
+	// mode so that the forward timeout is reached first
+	writeTimeout := 30 * time.Second
+	if syncForward {
+		writeTimeout = forwardTimeout + 10*time.Second
+	}
+	if writeTimeoutEnv != "" {
+		if parsed, err := strconv.ParseInt(writeTimeoutEnv, 10, 64); err == nil && parsed > 0 {
+			writeTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+	slog.Debug("Server timeouts", "read", readTimeout, "write", writeTimeout, "idle", idleTimeout)
+
+	// Open the disk-backed forward queue (disabled by default)
+	var queue *PersistentQueue
+	if persistQueue {
+		if queueFile == "" {
+			queueFile = "queue.json"
+		}
+		queue, err = OpenPersistentQueue(queueFile)
+		if err != nil {
+			fatal("Failed to open forward queue", "path", queueFile, "error", err)
+		}
+		slog.Debug("Persistent forward queue ENABLED", "path", queueFile)
+	}
+
+	RegisterMetrics()
+
+	// Export traces when an OTLP endpoint is configured (no-op otherwise)
+	shutdownTracing, err := SetupTracing(context.Background(), otlpEndpoint)
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+	if otlpEndpoint != "" {
+		slog.Debug("OpenTelemetry tracing ENABLED", "endpoint", otlpEndpoint)
+	}
+
+	tracker := &ForwardTracker{}
+
+	// Cancelled when shutdown gives up on pending forwards
+	forwardCtx, cancelForwards := context.WithCancel(context.Background())
+	defer cancelForwards()
+
+	// Parse the Authorization scheme of forwards (default to Bearer)
+	authScheme := "Bearer"
+	if authSchemeSet {
+		authScheme, err = ParseAuthScheme(sanitizeEnv(authSchemeEnv))
+		if err != nil {
+			fatal("Invalid AUTH_SCHEME", "error", err)
+		}
+		slog.Debug("Authorization scheme for forwards", "scheme", authScheme)
+	}
+
+	// Parse what counts as a successful forward (default to any 2xx status)
+	var successCriteria *SuccessCriteria
+	if successStatusCodesEnv != "" || successBodyRegexEnv != "" {
+		successCriteria = &SuccessCriteria{}
+		if successStatusCodesEnv != "" {
+			successCriteria.StatusCodes, err = ParseStatusCodes(successStatusCodesEnv)
+			if err != nil {
+				fatal("Invalid SUCCESS_STATUS_CODES", "error", err)
+			}
+		}
+		if successBodyRegexEnv != "" {
+			successCriteria.BodyRegex, err = regexp.Compile(successBodyRegexEnv)
+			if err != nil {
+				fatal("Invalid SUCCESS_BODY_REGEX", "value", successBodyRegexEnv, "error", err)
+			}
+		}
+		slog.Debug("Custom success criteria for forwards", "status_codes", successStatusCodesEnv, "body_regex", successBodyRegexEnv)
+	}
+
+	forwarder := &Forwarder{
+		Client:       NewForwardClient(requestTimeout, forwardProxy),
+		APIKey:       initialSettings.APIKey,
+		AuthScheme:   authScheme,
+		Success:      successCriteria,
+		Routes:       initialSettings.Routes,
+		BasicUser:    basicUser,
+		BasicPass:    basicPass,
+		Path:         watchtowerPath,
+		FormatPaths:  formatPaths,
+		Method:       forwardMethod,
+		MaxRetries:   maxRetries,
+		RetryBackoff: time.Second,
+		DryRun:       dryRun,
+		BodyTemplate: bodyTemplate,
+		ContentType:  forwardContentType,
+		UserAgent:    userAgent,
+		Fallback:     fallbackURL,
+		Headers:      forwardHeaders,
+		Health:       targetHealth,
+		LogTemplate:  logTemplate,
+	}
+
+	// Replaced with the new API key and routes by POST /api/reload
+	var activeForwarder atomic.Pointer[Forwarder]
+	activeForwarder.Store(forwarder)
+	if basicUser != "" {
+		slog.Warn("Basic auth for Watchtower forwards is ENABLED - the Watchtower token is sent in "+WatchtowerAuthorizationHeader+", the gate must restore it as Authorization",
+			"example", "proxy_set_header Authorization $http_x_watchtower_authorization")
+	}
+	if dryRun {
+		slog.Warn("DRY_RUN is ENABLED - webhooks will never be forwarded to Watchtower")
 	}
 
-	if webhookID == "" {
-		log.Fatal("WEBHOOK_ID environment variable is required")
+	// Coalesce identical webhooks while a forward is pending; the debouncer
+	// and the batcher already coalesce them when enabled
+	var inFlight *InFlight
+	if debouncer == nil && batcher == nil {
+		inFlight = NewInFlight()
 	}
-	if apiKey == "" {
-		log.Fatal("WATCHTOWER_API_KEY environment variable is required")
+
+	// Reports forward outcomes to Docker Hub when DOCKERHUB_CALLBACK is set
+	callbackClient := newNotifyClient()
+
+	// Posts webhooks to MIRROR_URL
+	mirrorClient := newNotifyClient()
+
+	// deliver forwards hook to every target, honoring the concurrency limit
+	// and the minimum interval per target, and notifies the outcomes.
+	deliver := func(ctx context.Context, hook *Webhook, targets []string) []*ForwardResult {
+		logger := hook.Logger()
+
+		// Wait for a free slot when the concurrency limit is reached
+		if forwardSlots != nil {
+			select {
+			case forwardSlots <- struct{}{}:
+			default:
+				logger.Info("Concurrent forward limit reached - waiting for a free slot", "limit", cap(forwardSlots))
+				select {
+				case forwardSlots <- struct{}{}:
+				case <-ctx.Done():
+					logCancelled(ctx, logger)
+					return nil
+				}
+			}
+			defer func() { <-forwardSlots }()
+		}
+
+		// Forward to every target independently
+		var results []*ForwardResult
+		for _, target := range targets {
+			if throttle != nil && !throttle.Allow(target) {
+				logger.Info("Forward coalesced - target was updated recently", "target", target, "min_interval", throttle.MinInterval)
+				countOutcome(hook.Repository, OutcomeCoalesced)
+				results = append(results, &ForwardResult{Target: target, Coalesced: true})
+				continue
+			}
+			result := activeForwarder.Load().ForwardWithFallback(ctx, hook, target)
+			notifiers.Notify(hook, result)
+			results = append(results, result)
+		}
+		countForward(hook, results)
+
+		// Report the outcome back to Docker Hub
+		if hook.CallbackURL != "" {
+			_, ok := Summarize(results)
+			SendDockerHubCallback(callbackClient, hook.CallbackURL, ok, logger)
+		}
+
+		// Only remember the digest once it reached Watchtower
+		if digests != nil && hook.Digest != "" && len(results) > 0 {
+			if _, ok := Summarize(results); ok {
+				digests.Record(hook.Repository+":"+hook.Tag, hook.Digest)
+			}
+		}
+		return results
 	}
-	if port == "" {
-		port = "3000" // default port
+
+	// schedule forwards hook to targets at forwardAt. Forwards sharing a key
+	// are debounced, and the forward is persisted until it has succeeded.
+	schedule := func(key string, hook *Webhook, targets []string, forwardAt time.Time) {
+		logger := hook.Logger()
+
+		var queueID string
+		if queue != nil {
+			queueID = uuid.NewString()
+			entry := QueuedForward{Key: key, ID: queueID, Webhook: hook, Targets: targets, ForwardAt: forwardAt}
+			if err := queue.Add(entry); err != nil {
+				logger.Error("Failed to persist queued forward", "error", err)
+			}
+		}
+
+		// Never hold a webhook longer than MAX_DELAY_SECONDS after it was
+		// first seen, whatever the debounce resets and the delay
+		capDelay := func(firstSeen time.Time) {
+			if deadline := firstSeen.Add(maxDelay); maxDelay > 0 && forwardAt.After(deadline) {
+				logger.Info("Maximum delay reached - shortening forward delay", "first_seen", firstSeen, "max_delay", maxDelay)
+				forwardAt = deadline
+			}
+		}
+
+		job := func() {
+			// Keep the server alive if a forward panics
+			defer recoverForward(logger)
+			defer tracker.Done()
+			if inFlight != nil {
+				defer inFlight.Finish(hook.Repository + ":" + hook.Tag)
+			}
+
+			// Failed forwards and those interrupted by shutdown stay queued
+			// and are re-sent on the next start
+			delivered := false
+			defer func() {
+				if queue != nil && delivered {
+					if err := queue.Remove(key, queueID); err != nil {
+						logger.Error("Failed to remove forward from queue", "error", err)
+					}
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(forwardCtx, forwardTimeout)
+			defer cancel()
+
+			// Continue the trace of the webhook that queued this forward
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(hook.TraceContext))
+			ctx, span := tracer.Start(ctx, "forward")
+			defer span.End()
+
+			// Add delay before forwarding, skipped entirely when disabled
+			if err := waitForForward(ctx, logger, forwardAt); err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				logCancelled(ctx, logger)
+				return
+			}
+
+			results := deliver(ctx, hook, targets)
+			_, ok := Summarize(results)
+			delivered = ok && len(results) > 0
+			if queue != nil && !delivered {
+				logger.Warn("Forward failed - keeping it queued until the next start")
+			}
+		}
+
+		tracker.Add()
+		if batcher != nil {
+			// Only the last webhook of a batch is forwarded, so release the
+			// slot of the one it replaces
+			if batcher.Add(key, func(firstSeen time.Time, _ int) {
+				capDelay(firstSeen)
+				job()
+			}) {
+				tracker.Done()
+			}
+		} else if debouncer != nil {
+			// A cancelled timer never runs its job, so release its slot
+			if debouncer.Trigger(key, func(firstSeen time.Time) {
+				capDelay(firstSeen)
+				job()
+			}) {
+				tracker.Done()
+			}
+		} else {
+			capDelay(time.Now())
+			go job()
+		}
 	}
 
-	// Create router
-	r := mux.NewRouter()
+	// Set by /api/drain to reject new webhooks while pending forwards finish
+	var draining atomic.Bool
 
-	// Health check endpoint
-	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// Used to confirm SNS subscriptions for ECR events
+	snsClient := &http.Client{Timeout: 10 * time.Second}
+
+	// Create router, mounting the routes under BASE_PATH when set
+	router := mux.NewRouter()
+	r := router
+	if basePath != "" {
+		r = router.PathPrefix(basePath).Subrouter()
+	}
+
+	// Liveness endpoint, OK as long as the process serves requests
+	livez := func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
+	}
+	r.HandleFunc("/livez", livez).Methods("GET")
+	r.HandleFunc("/health", livez).Methods("GET")
+
+	// Readiness endpoint, only OK when not draining and Watchtower is reachable
+	readiness := &ReadinessChecker{
+		Targets: initialSettings.WatchtowerURLs,
+		Client:  &http.Client{Timeout: 3 * time.Second},
+	}
+	readyz := func(w http.ResponseWriter, r *http.Request) {
+		if draining.Load() {
+			writeError(w, http.StatusServiceUnavailable, "Proxy is draining")
+			return
+		}
+		if err := readiness.Check(r.Context()); err != nil {
+			slog.Warn("Readiness check failed", "error", err)
+			writeError(w, http.StatusServiceUnavailable, "Watchtower unreachable")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+	r.HandleFunc("/readyz", readyz).Methods("GET")
+	r.HandleFunc("/ready", readyz).Methods("GET")
+
+	// Build information of the running binary
+	build := CurrentBuild()
+	r.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(build)
+	}).Methods("GET")
+
+	// Prometheus metrics endpoint
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	// Manual trigger endpoint, forwards synchronously and reports the result
+	r.HandleFunc("/api/trigger", func(w http.ResponseWriter, r *http.Request) {
+		settings := activeSettings.Load()
+		if !authorized(r, settings.APIKey, webhookSecret) {
+			slog.Warn("Unauthorized manual trigger attempt")
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		slog.Info("Manual trigger requested - forwarding to Watchtower")
+		hook := &Webhook{ID: "manual-trigger"}
+
+		results, ok := activeForwarder.Load().ForwardAll(r.Context(), hook, settings.WatchtowerURLs)
+		status := http.StatusOK
+		if !ok {
+			status = http.StatusBadGateway
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"results": results})
+	}).Methods("POST")
+
+	// Recent webhook history
+	r.HandleFunc("/api/history", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, activeSettings.Load().APIKey) {
+			slog.Warn("Unauthorized history request")
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"webhooks": history.Entries()})
+	}).Methods("GET")
+
+	// Stop accepting webhooks ahead of a shutdown
+	r.HandleFunc("/api/drain", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, activeSettings.Load().APIKey) {
+			slog.Warn("Unauthorized drain request")
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		draining.Store(true)
+		pending := tracker.Pending()
+		slog.Info("Draining - new webhooks will be rejected", "pending", pending)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"Draining - new webhooks are rejected","pending":` + strconv.FormatInt(pending, 10) + `}`))
+	}).Methods("POST")
+
+	// Replay a webhook from the history
+	r.HandleFunc("/api/replay/{historyID}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, activeSettings.Load().APIKey) {
+			slog.Warn("Unauthorized replay request")
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		historyID := mux.Vars(r)["historyID"]
+		entry, found := history.Find(historyID)
+		if !found {
+			writeError(w, http.StatusNotFound, "History entry not found")
+			return
+		}
+		if !entry.Replayable() {
+			writeError(w, http.StatusConflict, "Webhook was not forwarded and cannot be replayed")
+			return
+		}
+
+		hook := *entry.webhook
+		hook.RequestID = uuid.NewString()
+		hook.Logger().Info("Replaying webhook", "history_id", historyID)
+
+		results, ok := activeForwarder.Load().ForwardAll(r.Context(), &hook, entry.targets)
+		status := http.StatusOK
+		if !ok {
+			status = http.StatusBadGateway
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"history_id": historyID, "results": results})
+	}).Methods("POST")
+
+	// Counters since startup, a lightweight alternative to /metrics
+	r.HandleFunc("/api/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		snapshot := stats.Snapshot()
+		snapshot.PendingForwards = tracker.Pending()
+		if targetHealth != nil {
+			snapshot.Targets = targetHealth.Snapshot()
+		}
+		json.NewEncoder(w).Encode(snapshot)
+	}).Methods("GET")
+
+	// Re-reads CONFIG_FILE and swaps the settings derived from it. Forwards
+	// already scheduled keep the settings they were created with.
+	var reloadMu sync.Mutex
+	r.HandleFunc("/api/reload", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, activeSettings.Load().APIKey) {
+			slog.Warn("Unauthorized reload request")
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if configFile == "" {
+			writeError(w, http.StatusBadRequest, "CONFIG_FILE is not set")
+			return
+		}
+
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+
+		cfg, err := LoadConfig(configFile)
+		var settings *Settings
+		if err == nil {
+			settings, err = NewSettings(cfg, tagCaseInsensitive, ignoreTagPatterns)
+		}
+		if err != nil {
+			slog.Error("Configuration reload failed - keeping the current configuration", "path", configFile, "error", err)
+			writeError(w, http.StatusBadRequest, "Invalid configuration: "+err.Error())
+			return
+		}
+
+		forwarder := *activeForwarder.Load()
+		forwarder.APIKey = settings.APIKey
+		forwarder.Routes = settings.Routes
+		activeForwarder.Store(&forwarder)
+		readiness.SetTargets(settings.WatchtowerURLs)
+		activeSettings.Store(settings)
+		slog.Info("Configuration reloaded", "path", configFile)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Configuration reloaded"})
+	}).Methods("POST")
+
+	// Runs the parser and the filters on a posted payload without forwarding,
+	// to help configuring PAYLOAD_FORMAT and the watch rules
+	r.HandleFunc("/api/parse", func(w http.ResponseWriter, r *http.Request) {
+		settings := activeSettings.Load()
+		if !authorized(r, settings.APIKey) {
+			slog.Warn("Unauthorized parse request")
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if _, ok := err.(*http.MaxBytesError); ok {
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+
+		format := DetectPayloadFormat(r.Header)
+		if format == "" {
+			format = strings.ToLower(payloadFormat)
+		}
+		if format == "" {
+			format = FormatDockerHub
+		}
+		requestParser, _ := NewPayloadParser(format)
+		payload, err := requestParser.Parse(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Failed to parse payload as "+format+": "+err.Error())
+			return
+		}
+
+		// Apply the same checks as the webhook endpoint, in the same order
+		candidates := settings.TagFilter.Unignored(payload.Tags)
+		matched, watched := settings.TagFilter.MatchAny(candidates)
+		permitted, reason := repoFilter.Permit(payload.Repository)
+		switch {
+		case payload.SkipReason != "":
+			reason = payload.SkipReason
+		case !permitted:
+		case len(payload.Tags) > 0 && len(candidates) == 0:
+			reason = "tag is ignored"
+		case settings.WatchOnly && !watched:
+			reason = "tag is not " + settings.TagFilter.Describe()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"format":        format,
+			"repository":    payload.Repository,
+			"tags":          payload.Tags,
+			"digest":        payload.Digest,
+			"matched_tag":   matched,
+			"would_forward": reason == "",
+			"reason":        reason,
+			"targets":       settings.Routes.Targets(payload.Repository, settings.WatchtowerURLs),
+		})
+	}).Methods("POST")
+
+	// Effective configuration with secrets redacted
+	r.HandleFunc("/api/config", func(w http.ResponseWriter, r *http.Request) {
+		settings := activeSettings.Load()
+		if !authorized(r, settings.APIKey) {
+			slog.Warn("Unauthorized config request")
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		format := strings.ToLower(payloadFormat)
+		if format == "" {
+			format = FormatDockerHub
+		}
+		var debounceSeconds float64
+		if debouncer != nil {
+			debounceSeconds = debouncer.Window.Seconds()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"webhook_ids":               settings.Config.WebhookIDs,
+			"api_key":                   redactSecret(settings.APIKey),
+			"webhook_secret":            redactSecret(webhookSecret),
+			"signature_header":          signatureHeader,
+			"signature_algo":            signatureAlgo,
+			"gitlab_webhook_token":      redactSecret(gitlabToken),
+			"basic_user":                basicUser,
+			"basic_pass":                redactSecret(basicPass),
+			"slack_webhook_url":         redactSecret(slackWebhookURL),
+			"discord_webhook_url":       redactSecret(discordWebhookURL),
+			"telegram_bot_token":        redactSecret(telegramBotToken),
+			"telegram_chat_id":          telegramChatID,
+			"payload_format":            format,
+			"watchtower_urls":           settings.WatchtowerURLs,
+			"watchtower_path":           watchtowerPath,
+			"watchtower_path_overrides": formatPaths,
+			"auth_scheme":               authScheme,
+			"forward_method":            forwardMethod,
+			"forward_header_allowlist":  forwardHeaderAllowlist,
+			"routes":                    settings.Routes,
+			"watch_only":                settings.WatchOnly,
+			"watch_rules":               settings.TagFilter.Describe(),
+			"ignore_tag_patterns":       settings.TagFilter.Ignore,
+			"allowed_repos":             repoFilter.Allowed,
+			"denied_repos":              repoFilter.Denied,
+			"delay_seconds":             settings.Config.DelaySeconds,
+			"delay_overrides":           settings.Config.DelayOverrides,
+			"delay_jitter_seconds":      delayJitter.Seconds(),
+			"debounce_seconds":          debounceSeconds,
+			"max_retries":               maxRetries,
+			"forward_timeout_seconds":   forwardTimeout.Seconds(),
+			"request_timeout_seconds":   requestTimeout.Seconds(),
+			"dry_run":                   dryRun,
+		})
 	}).Methods("GET")
 
 	// Webhook proxy endpoint
-	r.HandleFunc("/api/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+	r.Handle("/api/webhooks/{id}", allowlist.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := activeSettings.Load()
 		vars := mux.Vars(r)
 		id := vars["id"]
 
-		// Verify the webhook ID matches
-		if id != webhookID {
-			log.Printf("Invalid webhook ID received: %s", id)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		// Reuse the caller's request ID or generate one for tracing
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		logger := slog.With("webhook_id", id, "request_id", requestID)
+
+		// Root span of the webhook, continued by the asynchronous forward
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "webhook", trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("webhook.id", id), attribute.String("request.id", requestID)))
+		defer span.End()
+
+		if draining.Load() {
+			logger.Warn("Webhook rejected - proxy is draining")
+			writeError(w, http.StatusServiceUnavailable, "Proxy is draining")
 			return
 		}
-		log.Printf("DEBUG: Webhook ID validated successfully")
 
-		// Read request body once
-		body, err := io.ReadAll(r.Body)
+		// Verify the webhook ID is one of the accepted IDs, continuing with
+		// the configured spelling
+		matchedID, ok := matchWebhookID(settings.WebhookIDs, id, webhookIDCaseInsensitive)
+		if !ok {
+			logger.Warn("Invalid webhook ID received")
+			writeError(w, http.StatusUnauthorized, "Invalid webhook ID")
+			return
+		}
+		id = matchedID
+		logger.Debug("Webhook ID validated successfully", "matched_id", id)
+
+		// Enforce the per-ID rate limit
+		if rateLimiter != nil && !rateLimiter.Allow(id) {
+			logger.Warn("Rate limit exceeded - rejecting webhook")
+			writeError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		// Read request body once, bounded to protect memory
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if maxBytesErr, ok := err.(*http.MaxBytesError); ok {
+			logger.Warn("Request body too large - rejecting webhook", "limit", maxBytesErr.Limit)
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
 		if err != nil {
-			log.Printf("ERROR: Failed to read request body: %v", err)
-			http.Error(w, "Bad Request", http.StatusBadRequest)
+			logger.Error("Failed to read request body", "error", err)
+			writeError(w, http.StatusBadRequest, "Failed to read request body")
 			return
 		}
 
-		// Check tag validation if enabled
+		// Decompress gzip bodies so that signatures, parsing and templates
+		// see the original payload
+		gzipped := isGzipped(r)
+		if gzipped {
+			body, err = gunzip(body, maxBodyBytes)
+			if maxBytesErr, ok := err.(*http.MaxBytesError); ok {
+				logger.Warn("Decompressed request body too large - rejecting webhook", "limit", maxBytesErr.Limit)
+				writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			if err != nil {
+				logger.Warn("Failed to decompress gzip request body", "error", err)
+				writeError(w, http.StatusBadRequest, "Invalid gzip request body")
+				return
+			}
+			logger.Debug("Decompressed gzip request body", "bytes", len(body))
+		}
+
+		// Verify the body signature if a secret is configured
+		if webhookSecret != "" {
+			if !VerifySignature(webhookSecret, signatureAlgo, body, r.Header.Get(signatureHeader)) {
+				logger.Warn("Invalid or missing signature header", "header", signatureHeader)
+				writeError(w, http.StatusUnauthorized, "Invalid signature")
+				return
+			}
+			logger.Debug("Webhook signature validated successfully")
+		}
+
+		// Verify the GitLab token if configured
+		if gitlabToken != "" {
+			if !VerifyToken(gitlabToken, r.Header.Get(GitLabTokenHeader)) {
+				logger.Warn("Invalid or missing GitLab token header", "header", GitLabTokenHeader)
+				writeError(w, http.StatusUnauthorized, "Invalid GitLab token")
+				return
+			}
+			logger.Debug("GitLab token validated successfully")
+		}
+
+		// Copy the authenticated webhook to the mirror, whatever its outcome
+		if mirrorURL != nil {
+			MirrorWebhook(mirrorClient, mirrorURL, body, r.Header.Get("Content-Type"), requestID, logger)
+		}
+
+		// Parse the payload if tag validation or routing needs it
 		var shouldForward = true
-		var payload DockerHubPayload
+		var repoName, tag, callbackURL, digest string
+		var hook *Webhook
+		targets := settings.WatchtowerURLs
+
+		// Log the received event with LOG_TEMPLATE, which has no default message
+		logReceived := func() {
+			if logTemplate != nil {
+				logger.Info(logTemplate.Message(LogData{Event: LogEventReceived, Repo: repoName, Tag: tag, WebhookID: id, RequestID: requestID}, "Webhook received"))
+			}
+		}
 
-		if watchOnly {
-			log.Printf("DEBUG: Tag validation enabled - parsing request body")
+		// Record the outcome in the webhook history
+		record := func(forwarded bool, reason string) {
+			history.Add(HistoryEntry{
+				ID:         uuid.NewString(),
+				Time:       time.Now(),
+				WebhookID:  id,
+				Repository: repoName,
+				Tag:        tag,
+				Forwarded:  forwarded,
+				Reason:     reason,
+				webhook:    hook,
+				targets:    targets,
+			})
+		}
+
+		// Pick the parser matching the sender, falling back to PAYLOAD_FORMAT
+		requestParser := parser
+		format := DetectPayloadFormat(r.Header)
+		if format != "" {
+			logger.Debug("Detected payload format", "format", format)
+			requestParser, _ = NewPayloadParser(format)
+		} else if format = strings.ToLower(payloadFormat); format == "" {
+			format = FormatDockerHub
+		}
+
+		if parsePayload(settings) || FiltersEvents(requestParser) {
+			logger.Debug("Payload-dependent features enabled - parsing request body")
 
 			// Parse JSON payload
-			if err := json.Unmarshal(body, &payload); err != nil {
-				log.Printf("ERROR: Failed to parse JSON payload: %v", err)
-				log.Printf("DEBUG: Raw payload: %s", string(body))
-				http.Error(w, "Bad Request", http.StatusBadRequest)
+			payload, err := requestParser.Parse(body)
+			if err != nil {
+				logger.Error("Failed to parse JSON payload", "error", err)
+				logger.Debug("Raw payload", "body", string(body))
+				countOutcome("", OutcomeRejected)
+				writeError(w, http.StatusBadRequest, "Failed to parse payload")
+				return
+			}
+
+			// Confirm SNS subscriptions instead of forwarding them
+			if payload.SubscribeURL != "" {
+				logger.Info("Confirming SNS subscription")
+				if err := ConfirmSNSSubscription(r.Context(), snsClient, payload.SubscribeURL); err != nil {
+					logger.Error("Failed to confirm SNS subscription", "error", err)
+					writeError(w, http.StatusBadGateway, "Failed to confirm SNS subscription")
+					return
+				}
+				record(false, "SNS subscription confirmation")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"message":"SNS subscription confirmed"}`))
+				return
+			}
+
+			tag = strings.Join(payload.Tags, ",")
+			repoName = payload.Repository
+			if dockerhubCallback {
+				callbackURL = payload.CallbackURL
+			}
+
+			// Drop ignored tags, keeping all of them when every tag is ignored
+			// so that the skip below reports what was pushed
+			candidates := settings.TagFilter.Unignored(payload.Tags)
+			allIgnored := len(payload.Tags) > 0 && len(candidates) == 0
+			if !allIgnored {
+				tag = strings.Join(candidates, ",")
+			}
+
+			// Check if any pushed tag matches the watch rules
+			matched, ok := settings.TagFilter.MatchAny(candidates)
+			if settings.WatchOnly && ok {
+				tag = matched
+			}
+
+			logger = logger.With("repository", repoName, "tag", tag)
+			span.SetAttributes(attribute.String("repository", repoName), attribute.String("tag", tag))
+			logger.Debug("Parsed webhook")
+			logReceived()
+			countOutcome(repoName, OutcomeReceived)
+
+			// Reject stale webhooks, e.g. replays of captured requests
+			if maxWebhookAge > 0 && !payload.Timestamp.IsZero() {
+				if age := time.Since(payload.Timestamp); age > maxWebhookAge {
+					logger.Warn("Stale webhook rejected", "timestamp", payload.Timestamp, "age", age.Round(time.Second), "max_age", maxWebhookAge)
+					countOutcome(repoName, OutcomeRejected)
+					writeError(w, http.StatusBadRequest, "Webhook is too old")
+					return
+				}
+			}
+
+			// Skip events that don't publish an image
+			if payload.SkipReason != "" {
+				logger.Debug("Event does not publish an image - skipping webhook forward", "reason", payload.SkipReason)
+				countOutcome(repoName, OutcomeSkipped)
+				record(false, payload.SkipReason)
+				writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook received but not forwarded - " + payload.SkipReason})
 				return
 			}
 
-			tag := payload.PushData.Tag
-			repoName := payload.Repository.Name
-			if repoName == "" {
-				repoName = payload.Repository.RepoName
+			// Check the repository against the allow and deny lists
+			if permitted, reason := repoFilter.Permit(repoName); !permitted {
+				logger.Debug("Repository filtered - skipping webhook forward", "reason", reason)
+				countOutcome(repoName, OutcomeSkipped)
+				record(false, reason)
+				writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook received but not forwarded - " + reason, "repository": repoName})
+				return
 			}
 
-			log.Printf("DEBUG: Parsed webhook - Repository: %s, Tag: %s", repoName, tag)
+			if allIgnored {
+				logger.Debug("Tag is ignored - skipping webhook forward", "patterns", strings.Join(settings.TagFilter.Ignore, ","))
+				countOutcome(repoName, OutcomeSkipped)
+				record(false, "tag is ignored")
+				writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook received but not forwarded - tag is ignored", "tag": tag})
+				return
+			}
 
-			// Check if tag is "latest"
-			if tag != "latest" {
-				log.Printf("DEBUG: Tag '%s' is not 'latest' - skipping webhook forward", tag)
+			if settings.WatchOnly && !ok {
+				logger.Debug("Tag is not watched - skipping webhook forward", "expected", settings.TagFilter.Describe())
 				shouldForward = false
+				countOutcome(repoName, OutcomeSkipped)
+				record(false, "tag is not "+settings.TagFilter.Describe())
 
 				// Respond with success but don't forward
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte(`{"message":"Webhook received but not forwarded - tag is not latest","tag":"` + tag + `"}`))
+				writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook received but not forwarded - tag is not " + settings.TagFilter.Describe(), "tag": tag})
 				return
-			} else {
-				log.Printf("DEBUG: Tag is 'latest' - will forward webhook asynchronously")
+			} else if settings.WatchOnly {
+				logger.Debug("Tag is watched - will forward webhook asynchronously")
 			}
+
+			// Skip pushes whose digest didn't change since the last webhook
+			if digests != nil && payload.Digest != "" {
+				if digests.Unchanged(repoName+":"+tag, payload.Digest) {
+					logger.Debug("Image digest unchanged - skipping webhook forward", "digest", payload.Digest)
+					countOutcome(repoName, OutcomeSkipped)
+					record(false, "image digest unchanged")
+					writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook received but not forwarded - image digest unchanged", "digest": payload.Digest})
+					return
+				}
+				digest = payload.Digest
+			}
+
+			targets = settings.Routes.Targets(repoName, settings.WatchtowerURLs)
+			logger.Debug("Repository routed", "targets", strings.Join(targets, ","))
+		} else {
+			logReceived()
+			countOutcome(repoName, OutcomeReceived)
 		}
 
-		// Copy headers we want to forward
-		headersToForward := make(map[string][]string)
-		for name, values := range r.Header {
-			if strings.ToLower(name) != "authorization" {
-				headersToForward[name] = values
+		// Copy the allowlisted headers, dropping cookies and internal headers
+		headersToForward := allowedHeaders(r.Header, forwardHeaderAllowlist)
+		if forwardClientIP {
+			if forwardedFor := r.Header.Values(ForwardedForHeader); len(forwardedFor) > 0 {
+				headersToForward[ForwardedForHeader] = forwardedFor
 			}
+			appendForwardedFor(headersToForward, r.RemoteAddr)
+		}
+		compress := gzipped && gzipForward == GzipForwardCompressed
+		if compress {
+			headersToForward["Content-Encoding"] = []string{"gzip"}
+		} else {
+			delete(headersToForward, "Content-Encoding")
 		}
 
-		// Respond immediately with 201 Accepted
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte(`{"message":"Webhook received and queued for processing","webhook_id":"` + id + `"}`))
-		log.Printf("DEBUG: Responded with 201 - processing webhook asynchronously")
+		hook = &Webhook{
+			ID:          id,
+			RequestID:   requestID,
+			Repository:  repoName,
+			Tag:         tag,
+			Body:        body,
+			Headers:     headersToForward,
+			Format:      format,
+			Gzip:        compress,
+			CallbackURL: callbackURL,
+			Digest:      digest,
+		}
+		hook.TraceContext = make(map[string]string)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(hook.TraceContext))
 
-		// Process webhook asynchronously if it should be forwarded
-		if shouldForward {
-			go func() {
-				// Add delay before forwarding
-				log.Printf("DEBUG: Starting %d second delay before forwarding webhook", delaySeconds)
-				time.Sleep(time.Duration(delaySeconds) * time.Second)
-				log.Printf("DEBUG: Delay completed - now forwarding webhook to Watchtower")
-
-				// Create request to Watchtower
-				client := &http.Client{
-					Timeout: 30 * time.Second,
-				}
+		// Skip identical webhooks while a forward for them is pending
+		if inFlight != nil && repoName != "" {
+			inFlightKey := repoName + ":" + tag
+			if !inFlight.Start(inFlightKey) {
+				logger.Info("Duplicate webhook coalesced - a forward is already pending for this tag")
+				countOutcome(repoName, OutcomeCoalesced)
+				record(false, "forward already pending")
+				writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook received but not forwarded - a forward is already pending", "tag": tag})
+				return
+			}
+			if syncForward {
+				defer inFlight.Finish(inFlightKey)
+			}
+		}
+
+		record(true, "")
+		stats.Accepted()
 
-				// Build the full Watchtower URL
-				watchtowerFullURL := watchtowerURL + "/v1/update"
-				log.Printf("DEBUG: Forwarding to Watchtower endpoint: %s", watchtowerFullURL)
+		// Forward before responding and report the Watchtower results
+		if syncForward {
+			syncCtx, cancel := context.WithTimeout(ctx, forwardTimeout)
+			defer cancel()
 
-				req, err := http.NewRequest("POST", watchtowerFullURL, strings.NewReader(string(body)))
-				if err != nil {
-					log.Printf("ERROR: Failed to create request: %v", err)
+			if delaySeconds := settings.Config.DelayFor(repoName); syncForwardDelay && delaySeconds > 0 {
+				logger.Debug("Applying forward delay before responding", "seconds", delaySeconds)
+				if err := sleepContext(syncCtx, time.Duration(delaySeconds)*time.Second); err != nil {
+					logCancelled(syncCtx, logger)
+					writeError(w, http.StatusGatewayTimeout, "Forward cancelled")
 					return
 				}
+			}
 
-				// Add authorization header
-				req.Header.Set("Authorization", "Bearer "+apiKey)
-				req.Header.Set("Content-Type", "application/json")
-				log.Printf("DEBUG: Added Authorization header and Content-Type")
+			forwarded := deliver(syncCtx, hook, targets)
 
-				// Forward original request headers
-				for name, values := range headersToForward {
-					req.Header[name] = values
+			// Relay the Watchtower response as is
+			if result := PassthroughResult(forwarded); syncForwardPassthrough && result != nil {
+				if result.Err != nil {
+					writeError(w, http.StatusBadGateway, "Watchtower could not be reached")
+					return
+				}
+				body := result.Body
+				if len(body) > syncForwardMaxBody {
+					logger.Warn("Watchtower response body truncated", "bytes", len(body), "limit", syncForwardMaxBody)
+					body = body[:syncForwardMaxBody]
+				}
+				if result.ContentType != "" {
+					w.Header().Set("Content-Type", result.ContentType)
 				}
+				w.WriteHeader(result.StatusCode)
+				w.Write(body)
+				return
+			}
 
-				log.Printf("DEBUG: Executing request to Watchtower...")
+			results, ok := Summarize(forwarded)
+			status := http.StatusOK
+			if !ok || len(results) == 0 {
+				status = http.StatusBadGateway
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]any{"webhook_id": id, "results": results})
+			return
+		}
 
-				// Execute request
-				resp, err := client.Do(req)
-				if err != nil {
-					log.Printf("ERROR: Failed to forward request to Watchtower: %v", err)
-					return
-				}
-				defer resp.Body.Close()
+		// Respond immediately (201 by default)
+		w.WriteHeader(responseStatus)
+		w.Write([]byte(`{"message":"Webhook received and queued for processing","webhook_id":"` + id + `"}`))
+		logger.Debug("Responded - processing webhook asynchronously", "status", responseStatus)
 
-				// Log response details for debugging
-				log.Printf("DEBUG: Watchtower response - Status: %d, Headers: %v", resp.StatusCode, resp.Header)
+		// Process webhook asynchronously if it should be forwarded
+		if shouldForward {
+			delaySeconds := settings.Config.DelayFor(repoName)
+			delay := time.Duration(delaySeconds) * time.Second
+			if delayJitter > 0 {
+				// Spread forwards of simultaneous pushes over the jitter window
+				delay += rand.N(delayJitter + 1)
+			}
+			logger.Info("Forward scheduled", "delay", delay.Round(time.Millisecond), "base_seconds", delaySeconds, "override", delaySeconds != settings.Config.DelaySeconds)
 
-				// If we get a 404, provide helpful guidance
-				if resp.StatusCode == 404 {
-					log.Printf("ERROR: 404 - Watchtower endpoint not found. Current URL: %s", watchtowerFullURL)
-					log.Printf("DEBUG: Common Watchtower endpoints to try: /v1/update, /api/update, /webhook")
-				}
+			// Debounced forwards share a key so that a newer push replaces the
+			// pending one, including in the persistent queue
+			key := uuid.NewString()
+			if batcher != nil {
+				key = "batch:" + strings.Join(targets, ",")
+			} else if debouncer != nil {
+				key = repoName + ":" + tag
+			}
+			schedule(key, hook, targets, time.Now().Add(delay))
+		}
+	}))).Methods("POST")
 
-				// Read response body for logging
-				respBody, err := io.ReadAll(resp.Body)
-				if err != nil {
-					log.Printf("ERROR: Failed to read response body: %v", err)
-				} else {
-					log.Printf("DEBUG: Watchtower response body: %s", string(respBody))
-				}
+	// Re-enqueue forwards left over from a previous run
+	if queue != nil {
+		entries := queue.Entries()
+		if len(entries) > 0 {
+			slog.Info("Restoring queued forwards", "count", len(entries))
+		}
+		for _, entry := range entries {
+			if inFlight != nil {
+				inFlight.Start(entry.Webhook.Repository + ":" + entry.Webhook.Tag)
+			}
+			schedule(entry.Key, entry.Webhook, entry.Targets, entry.ForwardAt)
+		}
+	}
 
-				if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-					log.Printf("SUCCESS: Webhook %s forwarded to Watchtower successfully - Status: %d", id, resp.StatusCode)
-				} else {
-					log.Printf("WARNING: Webhook %s forwarded but got non-success status: %d", id, resp.StatusCode)
-				}
-			}()
+	// Answer unknown paths and methods with JSON listing the valid endpoints
+	router.NotFoundHandler = UnmatchedHandler(router)
+	router.MethodNotAllowedHandler = router.NotFoundHandler
+
+	server := &http.Server{
+		Addr:         listenAddress,
+		Handler:      router,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+
+	go func() {
+		for id := range initialSettings.WebhookIDs {
+			slog.Info("Webhook endpoint: " + basePath + "/api/webhooks/" + id)
 		}
-	}).Methods("POST")
+		var err error
+		if tlsCertFile != "" {
+			slog.Info("Starting proxy server with TLS", "version", build.Version, "commit", build.Commit, "build_date", build.BuildDate, "address", listenAddress, "cert", tlsCertFile)
+			err = server.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
+		} else {
+			slog.Info("Starting proxy server", "version", build.Version, "commit", build.Commit, "build_date", build.BuildDate, "address", listenAddress)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("Server failed", "error", err)
+		}
+	}()
+
+	// Report unreachable Watchtower targets without delaying the startup
+	if startupCheck {
+		targets := slices.Clone(initialSettings.WatchtowerURLs)
+		for _, route := range initialSettings.Routes {
+			targets = append(targets, route.Target)
+		}
+		if fallbackURL != "" {
+			targets = append(targets, fallbackURL)
+		}
+		go StartupCheck(context.Background(), &http.Client{Timeout: 5 * time.Second}, targets)
+	}
+
+	// Wait for a termination signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	slog.Info("Shutdown signal received - stopping server")
 
-	log.Printf("Starting proxy server on port %s", port)
-	log.Printf("Webhook endpoint: /api/webhooks/%s", webhookID)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down server cleanly", "error", err)
+	}
+
+	// Drain pending forwards
+	pending := tracker.Pending()
+	slog.Info("Waiting for pending forwards", "timeout", shutdownTimeout, "pending", pending)
+	if tracker.Wait(shutdownTimeout) {
+		slog.Info("Drained pending forwards - shutdown complete", "drained", pending)
+	} else {
+		remaining := tracker.Pending()
+		slog.Warn("Shutdown timeout reached - cancelling remaining forwards", "drained", pending-remaining, "abandoned", remaining)
+		cancelForwards()
+	}
+
+	// Flush buffered spans
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Webhook outcomes used as the "outcome" metric label.
+const (
+	OutcomeReceived  = "received"
+	OutcomeForwarded = "forwarded"
+	OutcomeSkipped   = "skipped"
+	OutcomeFailed    = "failed"
+	OutcomeCoalesced = "coalesced"
+	OutcomeRejected  = "rejected"
+)
+
+var (
+	webhooksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "watchtower_proxy_webhooks_total",
+			Help: "Number of webhooks by repository and outcome.",
+		},
+		[]string{"repository", "outcome"},
+	)
+
+	forwardDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "watchtower_proxy_forward_duration_seconds",
+			Help:    "Latency of forward requests to Watchtower.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"repository", "outcome"},
+	)
+
+	pendingForwards = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "watchtower_proxy_pending_forwards",
+			Help: "Number of scheduled forwards that have not finished, including those waiting for their delay.",
+		},
+	)
+
+	responsesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "watchtower_proxy_watchtower_responses_total",
+			Help: "Number of Watchtower responses by category.",
+		},
+		[]string{"category"},
+	)
+)
+
+// RegisterMetrics registers the proxy collectors with the default registry.
+func RegisterMetrics() {
+	prometheus.MustRegister(webhooksTotal, forwardDuration, responsesTotal, pendingForwards)
+}
+
+// countOutcome records a webhook outcome in the metrics and the stats.
+func countOutcome(repository, outcome string) {
+	webhooksTotal.WithLabelValues(repository, outcome).Inc()
+	stats.Record(outcome)
+}
+
+// countResponse records the category of a Watchtower response in the metrics
+// and the stats.
+func countResponse(category string) {
+	responsesTotal.WithLabelValues(category).Inc()
+	stats.RecordResponse(category)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// ParseMirrorURL validates a MIRROR_URL value, which must be an http or
+// https URL.
+func ParseMirrorURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("mirror URL %q must use http or https", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("mirror URL %q has no host", u.Redacted())
+	}
+	return u, nil
+}
+
+// MirrorWebhook posts a received webhook body to mirrorURL in the background,
+// keeping its Content-Type and request ID. The mirror is best-effort and
+// never affects the forward to Watchtower, failures are only logged.
+func MirrorWebhook(client *http.Client, mirrorURL *url.URL, body []byte, contentType, requestID string, logger *slog.Logger) {
+	logger = logger.With("mirror", mirrorURL.Redacted())
+
+	go func() {
+		req, err := http.NewRequest(http.MethodPost, mirrorURL.String(), bytes.NewReader(body))
+		if err != nil {
+			logger.Warn("Failed to create mirror request", "error", err)
+			return
+		}
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set(RequestIDHeader, requestID)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			logger.Warn("Failed to mirror webhook", "error", err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			logger.Warn("Webhook mirror rejected", "status", resp.StatusCode)
+			return
+		}
+		logger.Debug("Webhook mirrored", "status", resp.StatusCode, "bytes", len(body))
+	}()
+}
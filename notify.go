@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Supported values for NOTIFY_ON.
+const (
+	NotifyOnSuccess = "success"
+	NotifyOnFailure = "failure"
+	NotifyOnBoth    = "both"
+)
+
+// ParseNotifyOn validates a NOTIFY_ON value, defaulting to both.
+func ParseNotifyOn(value string) (string, error) {
+	switch value = strings.ToLower(value); value {
+	case "":
+		return NotifyOnBoth, nil
+	case NotifyOnSuccess, NotifyOnFailure, NotifyOnBoth:
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported NOTIFY_ON value %q, expected success, failure or both", value)
+	}
+}
+
+// Notifier reports the outcome of a forward. Implementations must not block
+// and must never affect the forward itself.
+type Notifier interface {
+	Notify(hook *Webhook, result *ForwardResult)
+}
+
+// Notifiers dispatches a forward outcome to every configured notifier.
+type Notifiers []Notifier
+
+// Notify sends the outcome to all notifiers.
+func (n Notifiers) Notify(hook *Webhook, result *ForwardResult) {
+	for _, notifier := range n {
+		notifier.Notify(hook, result)
+	}
+}
+
+// wantsNotification reports whether NOTIFY_ON selects the outcome.
+func wantsNotification(notifyOn string, success bool) bool {
+	return !(success && notifyOn == NotifyOnFailure) && !(!success && notifyOn == NotifyOnSuccess)
+}
+
+// postNotification posts a JSON message in the background. Failures are only
+// logged.
+func postNotification(client *http.Client, endpoint, service string, message any, logger *slog.Logger) {
+	go func() {
+		body, _ := json.Marshal(message)
+		resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+		if err != nil {
+			// Drop the URL from the error, it embeds the credentials
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			logger.Warn("Failed to send "+service+" notification", "error", err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			reply, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			logger.Warn(service+" notification rejected", "status", resp.StatusCode, "response", string(reply))
+			return
+		}
+		logger.Debug(service + " notification sent")
+	}()
+}
+
+// SlackNotifier posts forward outcomes to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookURL string
+	NotifyOn   string
+	Client     *http.Client
+}
+
+// Notify sends the outcome of a forward in the background.
+func (n *SlackNotifier) Notify(hook *Webhook, result *ForwardResult) {
+	success := result.Success()
+	if !wantsNotification(n.NotifyOn, success) {
+		return
+	}
+
+	text := fmt.Sprintf(":white_check_mark: Watchtower update triggered for *%s:%s* on %s (status %d)",
+		hook.Repository, hook.Tag, result.Target, result.StatusCode)
+	if !success {
+		text = fmt.Sprintf(":x: Watchtower update failed for *%s:%s* on %s (status %d)",
+			hook.Repository, hook.Tag, result.Target, result.StatusCode)
+		if result.Err != nil {
+			text += ": " + result.Err.Error()
+		}
+	}
+
+	logger := hook.Logger().With("target", result.Target)
+	postNotification(n.Client, n.WebhookURL, "Slack", map[string]string{"text": text}, logger)
+}
+
+// Embed colors used for Discord notifications.
+const (
+	discordColorSuccess = 0x2ecc71
+	discordColorFailure = 0xe74c3c
+)
+
+// DiscordNotifier posts forward outcomes as embeds to a Discord webhook.
+type DiscordNotifier struct {
+	WebhookURL string
+	NotifyOn   string
+	Client     *http.Client
+}
+
+// Notify sends the outcome of a forward in the background.
+func (n *DiscordNotifier) Notify(hook *Webhook, result *ForwardResult) {
+	success := result.Success()
+	if !wantsNotification(n.NotifyOn, success) {
+		return
+	}
+
+	title := "Watchtower update triggered"
+	color := discordColorSuccess
+	description := fmt.Sprintf("**%s:%s** on %s (status %d)", hook.Repository, hook.Tag, result.Target, result.StatusCode)
+	if !success {
+		title = "Watchtower update failed"
+		color = discordColorFailure
+		if result.Err != nil {
+			description += "\n" + result.Err.Error()
+		}
+	}
+
+	message := map[string]any{
+		"embeds": []map[string]any{{
+			"title":       title,
+			"description": description,
+			"color":       color,
+			"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		}},
+	}
+	logger := hook.Logger().With("target", result.Target)
+	postNotification(n.Client, n.WebhookURL, "Discord", message, logger)
+}
+
+// TelegramAPIURL is the base URL of the Telegram Bot API.
+const TelegramAPIURL = "https://api.telegram.org"
+
+// TelegramNotifier sends forward outcomes as messages to a Telegram chat
+// through the Bot API.
+type TelegramNotifier struct {
+	BotToken string
+	ChatID   string
+	NotifyOn string
+	Client   *http.Client
+}
+
+// Notify sends the outcome of a forward in the background.
+func (n *TelegramNotifier) Notify(hook *Webhook, result *ForwardResult) {
+	success := result.Success()
+	if !wantsNotification(n.NotifyOn, success) {
+		return
+	}
+
+	text := fmt.Sprintf("✅ Watchtower update triggered for %s:%s on %s (status %d)",
+		hook.Repository, hook.Tag, result.Target, result.StatusCode)
+	if !success {
+		text = fmt.Sprintf("❌ Watchtower update failed for %s:%s on %s (status %d)",
+			hook.Repository, hook.Tag, result.Target, result.StatusCode)
+		if result.Err != nil {
+			text += ": " + result.Err.Error()
+		}
+	}
+
+	message := map[string]string{"chat_id": n.ChatID, "text": text}
+	logger := hook.Logger().With("target", result.Target)
+	postNotification(n.Client, TelegramAPIURL+"/bot"+n.BotToken+"/sendMessage", "Telegram", message, logger)
+}
+
+// newNotifyClient returns the HTTP client used for notifications.
+func newNotifyClient() *http.Client {
+	return &http.Client{Timeout: 10 * time.Second}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Supported values for PAYLOAD_FORMAT.
+const (
+	FormatDockerHub = "dockerhub"
+	FormatGHCR      = "ghcr"
+	FormatGitLab    = "gitlab"
+	FormatQuay      = "quay"
+	FormatHarbor    = "harbor"
+	FormatGitea     = "gitea"
+	FormatACR       = "acr"
+	FormatECR       = "ecr"
+)
+
+// ParsedPayload holds the fields extracted from a registry webhook.
+type ParsedPayload struct {
+	Repository string
+	// Tags lists every tag pushed by the event; most registries send one.
+	Tags []string
+	// Digest is the image digest, empty when the registry doesn't send it.
+	Digest string
+	// SkipReason is set when the event must not trigger a forward, e.g. a
+	// package deletion.
+	SkipReason string
+	// SubscribeURL is set for SNS subscription confirmations, which carry no
+	// image and must be confirmed by visiting the URL.
+	SubscribeURL string
+	// Timestamp is when the event happened, zero when the registry doesn't
+	// send it.
+	Timestamp time.Time
+	// CallbackURL receives the outcome of the forward (Docker Hub only).
+	CallbackURL string
+}
+
+// PayloadParser extracts the repository and tag from a registry webhook body.
+type PayloadParser interface {
+	Parse(body []byte) (*ParsedPayload, error)
+}
+
+// NewPayloadParser returns the parser for the given PAYLOAD_FORMAT value.
+// Docker Hub is used when the format is empty.
+func NewPayloadParser(format string) (PayloadParser, error) {
+	switch strings.ToLower(format) {
+	case "", FormatDockerHub:
+		return DockerHubParser{}, nil
+	case FormatGHCR:
+		return GHCRParser{}, nil
+	case FormatGitLab:
+		return GitLabParser{}, nil
+	case FormatQuay:
+		return QuayParser{}, nil
+	case FormatHarbor:
+		return HarborParser{}, nil
+	case FormatGitea:
+		return GiteaParser{}, nil
+	case FormatACR:
+		return ACRParser{}, nil
+	case FormatECR:
+		return ECRParser{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported payload format %q", format)
+	}
+}
+
+// DetectPayloadFormat guesses the format of a webhook from the headers set by
+// the sending registry. It returns an empty string when the headers are
+// ambiguous, e.g. for Docker Hub which sends no identifying header.
+func DetectPayloadFormat(header http.Header) string {
+	switch {
+	// Gitea and Forgejo also send X-GitHub-Event for compatibility
+	case header.Get("X-Gitea-Event") != "", header.Get("X-Forgejo-Event") != "":
+		return FormatGitea
+	case header.Get("X-GitHub-Event") != "":
+		return FormatGHCR
+	case header.Get("X-Gitlab-Event") != "":
+		return FormatGitLab
+	case header.Get("X-Harbor-Event") != "":
+		return FormatHarbor
+	case header.Get("X-Amz-Sns-Message-Type") != "":
+		return FormatECR
+	}
+	return ""
+}
+
+// FiltersEvents reports whether the parser skips some events, in which case
+// every payload has to be parsed.
+func FiltersEvents(parser PayloadParser) bool {
+	switch parser.(type) {
+	case GitLabParser, HarborParser, GiteaParser, ACRParser, ECRParser:
+		return true
+	default:
+		return false
+	}
+}
+
+type DockerHubPayload struct {
+	CallbackURL string `json:"callback_url"`
+	PushData    struct {
+		Tag      string `json:"tag"`
+		PushedAt int64  `json:"pushed_at"`
+		// Images lists the pushed images; entries of the form name:tag carry
+		// additional tags when a build pushes several of them.
+		Images []string `json:"images"`
+	} `json:"push_data"`
+	Repository struct {
+		Name     string `json:"name"`
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+}
+
+// DockerHubParser parses Docker Hub push webhooks.
+type DockerHubParser struct{}
+
+func (DockerHubParser) Parse(body []byte) (*ParsedPayload, error) {
+	var payload DockerHubPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	repoName := payload.Repository.RepoName
+	if repoName == "" {
+		repoName = payload.Repository.Name
+	}
+
+	var tags []string
+	if payload.PushData.Tag != "" {
+		tags = append(tags, payload.PushData.Tag)
+	}
+	for _, image := range payload.PushData.Images {
+		// Skip image IDs, digests and references without a tag
+		i := strings.LastIndex(image, ":")
+		if i < 0 || strings.Contains(image[i+1:], "/") || strings.Contains(image, "@") || strings.HasPrefix(image, "sha256:") {
+			continue
+		}
+		if tag := image[i+1:]; tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	parsed := &ParsedPayload{Repository: repoName, Tags: tags, CallbackURL: payload.CallbackURL}
+	if payload.PushData.PushedAt > 0 {
+		parsed.Timestamp = time.Unix(payload.PushData.PushedAt, 0)
+	}
+	return parsed, nil
+}
+
+// GHCRPayload is the subset of the GitHub "registry_package" event used by
+// the proxy.
+type GHCRPayload struct {
+	Action          string `json:"action"`
+	RegistryPackage struct {
+		Name           string `json:"name"`
+		Namespace      string `json:"namespace"`
+		PackageVersion struct {
+			ContainerMetadata struct {
+				Tag struct {
+					Name   string `json:"name"`
+					Digest string `json:"digest"`
+				} `json:"tag"`
+			} `json:"container_metadata"`
+		} `json:"package_version"`
+	} `json:"registry_package"`
+}
+
+// GHCRParser parses GitHub Container Registry package webhooks.
+type GHCRParser struct{}
+
+func (GHCRParser) Parse(body []byte) (*ParsedPayload, error) {
+	var payload GHCRPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	pkg := payload.RegistryPackage
+	if pkg.Name == "" {
+		return nil, fmt.Errorf("missing registry_package in GHCR payload")
+	}
+
+	repoName := pkg.Name
+	if pkg.Namespace != "" {
+		repoName = pkg.Namespace + "/" + pkg.Name
+	}
+	tag := pkg.PackageVersion.ContainerMetadata.Tag
+	return &ParsedPayload{Repository: repoName, Tags: []string{tag.Name}, Digest: tag.Digest}, nil
+}
+
+// GitLabPayload is the registry notification envelope sent by the GitLab
+// container registry.
+type GitLabPayload struct {
+	Events []struct {
+		Action    string    `json:"action"`
+		Timestamp time.Time `json:"timestamp"`
+		Target    struct {
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+			Digest     string `json:"digest"`
+		} `json:"target"`
+	} `json:"events"`
+}
+
+// GitLabParser parses GitLab container registry push notifications.
+// Envelopes without a tagged push, e.g. pulls or deletes, are skipped.
+type GitLabParser struct{}
+
+func (GitLabParser) Parse(body []byte) (*ParsedPayload, error) {
+	var payload GitLabPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	for _, event := range payload.Events {
+		if event.Action == "push" && event.Target.Tag != "" {
+			return &ParsedPayload{Repository: event.Target.Repository, Tags: []string{event.Target.Tag}, Digest: event.Target.Digest, Timestamp: event.Timestamp}, nil
+		}
+	}
+	if len(payload.Events) == 0 {
+		return nil, fmt.Errorf("no event in GitLab payload")
+	}
+
+	// Report the first event so the skip is logged with its repository
+	event := payload.Events[0]
+	return &ParsedPayload{
+		Repository: event.Target.Repository,
+		Tags:       []string{event.Target.Tag},
+		Timestamp:  event.Timestamp,
+		SkipReason: "registry action " + event.Action + " is not a tagged push",
+	}, nil
+}
+
+// QuayPayload is the "Push to Repository" notification sent by Quay:
+//
+//	{
+//	  "repository": "mynamespace/repository",
+//	  "namespace": "mynamespace",
+//	  "name": "repository",
+//	  "docker_url": "quay.io/mynamespace/repository",
+//	  "homepage": "https://quay.io/repository/mynamespace/repository",
+//	  "updated_tags": ["latest", "1.2.0"]
+//	}
+type QuayPayload struct {
+	Repository  string   `json:"repository"`
+	Namespace   string   `json:"namespace"`
+	Name        string   `json:"name"`
+	DockerURL   string   `json:"docker_url"`
+	UpdatedTags []string `json:"updated_tags"`
+}
+
+// QuayParser parses Quay repository push notifications. Every updated tag is
+// reported so that the watch rules can match any of them.
+type QuayParser struct{}
+
+func (QuayParser) Parse(body []byte) (*ParsedPayload, error) {
+	var payload QuayPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	repoName := payload.Repository
+	if repoName == "" && payload.Name != "" {
+		repoName = payload.Namespace + "/" + payload.Name
+	}
+	if repoName == "" {
+		return nil, fmt.Errorf("missing repository in Quay payload")
+	}
+	if len(payload.UpdatedTags) == 0 {
+		return nil, fmt.Errorf("missing updated_tags in Quay payload")
+	}
+	return &ParsedPayload{Repository: repoName, Tags: payload.UpdatedTags}, nil
+}
+
+// HarborPayload is the webhook event sent by Harbor, e.g. for PUSH_ARTIFACT:
+//
+//	{
+//	  "type": "PUSH_ARTIFACT",
+//	  "event_data": {
+//	    "resources": [{"digest": "sha256:...", "tag": "latest", "resource_url": "harbor.example.com/library/nginx:latest"}],
+//	    "repository": {"name": "nginx", "namespace": "library", "repo_full_name": "library/nginx"}
+//	  }
+//	}
+type HarborPayload struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			Digest string `json:"digest"`
+			Tag    string `json:"tag"`
+		} `json:"resources"`
+		Repository struct {
+			Name         string `json:"name"`
+			RepoFullName string `json:"repo_full_name"`
+		} `json:"repository"`
+	} `json:"event_data"`
+}
+
+// HarborParser parses Harbor artifact push events. The tags of all resources
+// are reported so that the watch rules can match any of them, and other
+// event types are skipped.
+type HarborParser struct{}
+
+func (HarborParser) Parse(body []byte) (*ParsedPayload, error) {
+	var payload HarborPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	repoName := payload.EventData.Repository.RepoFullName
+	if repoName == "" {
+		repoName = payload.EventData.Repository.Name
+	}
+
+	parsed := &ParsedPayload{Repository: repoName}
+	if payload.Type != "" && payload.Type != "PUSH_ARTIFACT" {
+		parsed.SkipReason = "Harbor event " + payload.Type + " is not PUSH_ARTIFACT"
+		return parsed, nil
+	}
+
+	for _, resource := range payload.EventData.Resources {
+		if resource.Tag != "" {
+			parsed.Tags = append(parsed.Tags, resource.Tag)
+		}
+	}
+	if len(parsed.Tags) == 0 {
+		return nil, fmt.Errorf("no tagged resource in Harbor payload")
+	}
+	if len(payload.EventData.Resources) == 1 {
+		parsed.Digest = payload.EventData.Resources[0].Digest
+	}
+	return parsed, nil
+}
+
+// GiteaPayload is the package webhook sent by Gitea and Forgejo:
+//
+//	{
+//	  "action": "published",
+//	  "package": {
+//	    "owner": {"login": "myorg"},
+//	    "type": "container",
+//	    "name": "app",
+//	    "version": "1.2.0"
+//	  }
+//	}
+type GiteaPayload struct {
+	Action  string `json:"action"`
+	Package struct {
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+		Type    string `json:"type"`
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"package"`
+}
+
+// GiteaParser parses Gitea/Forgejo package webhooks. Only published packages
+// trigger a forward; Gitea reports them with the "created" action.
+type GiteaParser struct{}
+
+func (GiteaParser) Parse(body []byte) (*ParsedPayload, error) {
+	var payload GiteaPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	pkg := payload.Package
+	if pkg.Name == "" {
+		return nil, fmt.Errorf("missing package in Gitea payload")
+	}
+
+	repoName := pkg.Name
+	if pkg.Owner.Login != "" {
+		repoName = pkg.Owner.Login + "/" + pkg.Name
+	}
+	parsed := &ParsedPayload{Repository: repoName, Tags: []string{pkg.Version}}
+	if payload.Action != "published" && payload.Action != "created" {
+		parsed.SkipReason = "package action " + payload.Action + " is not published"
+	}
+	return parsed, nil
+}
+
+// ACRPayload is the webhook sent by Azure Container Registry:
+//
+//	{
+//	  "id": "cb8c3971-9adc-488b-xxxx-43cbb4974ff5",
+//	  "timestamp": "2017-11-17T16:52:01.343145347Z",
+//	  "action": "push",
+//	  "target": {
+//	    "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+//	    "digest": "sha256:80f0d5c8786bb9e621a45ece0db56d11cdc624ad20da9fe62e9d25490f331d7d",
+//	    "repository": "hello-world",
+//	    "tag": "v1"
+//	  },
+//	  "request": {"host": "myregistry.azurecr.io", "method": "PUT"}
+//	}
+type ACRPayload struct {
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+	Target    *struct {
+		Digest     string `json:"digest"`
+		Repository string `json:"repository"`
+		Tag        string `json:"tag"`
+	} `json:"target"`
+}
+
+// ACRParser parses Azure Container Registry webhooks. Only push events
+// trigger a forward.
+type ACRParser struct{}
+
+func (ACRParser) Parse(body []byte) (*ParsedPayload, error) {
+	var payload ACRPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	target := payload.Target
+	if target == nil || target.Repository == "" {
+		return nil, fmt.Errorf("missing target in ACR payload")
+	}
+
+	parsed := &ParsedPayload{Repository: target.Repository, Tags: []string{target.Tag}, Digest: target.Digest, Timestamp: payload.Timestamp}
+	if payload.Action != "push" {
+		parsed.SkipReason = "action " + payload.Action + " is not push"
+	}
+	return parsed, nil
+}
+
+// SNSMessage is the envelope of messages delivered by an SNS HTTP(S)
+// subscription.
+type SNSMessage struct {
+	Type         string    `json:"Type"`
+	Timestamp    time.Time `json:"Timestamp"`
+	Message      string    `json:"Message"`
+	SubscribeURL string    `json:"SubscribeURL"`
+}
+
+// ECREvent is the EventBridge "ECR Image Action" event relayed through SNS:
+//
+//	{
+//	  "detail-type": "ECR Image Action",
+//	  "source": "aws.ecr",
+//	  "detail": {
+//	    "result": "SUCCESS",
+//	    "repository-name": "my-repo",
+//	    "image-digest": "sha256:7f5b2640fe6fb4f46592dfd3410c4a79dac4f89e4782432e0378abcd1234",
+//	    "action-type": "PUSH",
+//	    "image-tag": "latest"
+//	  }
+//	}
+type ECREvent struct {
+	Detail struct {
+		Result         string `json:"result"`
+		RepositoryName string `json:"repository-name"`
+		ImageDigest    string `json:"image-digest"`
+		ActionType     string `json:"action-type"`
+		ImageTag       string `json:"image-tag"`
+	} `json:"detail"`
+}
+
+// ECRParser parses AWS ECR image events delivered by SNS. Only successful
+// pushes trigger a forward.
+type ECRParser struct{}
+
+func (ECRParser) Parse(body []byte) (*ParsedPayload, error) {
+	var envelope SNSMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+
+	switch envelope.Type {
+	case "SubscriptionConfirmation":
+		if envelope.SubscribeURL == "" {
+			return nil, fmt.Errorf("missing SubscribeURL in SNS subscription confirmation")
+		}
+		return &ParsedPayload{SubscribeURL: envelope.SubscribeURL}, nil
+	case "Notification":
+	default:
+		return nil, fmt.Errorf("unsupported SNS message type %q", envelope.Type)
+	}
+
+	var event ECREvent
+	if err := json.Unmarshal([]byte(envelope.Message), &event); err != nil {
+		return nil, fmt.Errorf("invalid ECR event in SNS message: %w", err)
+	}
+	detail := event.Detail
+	if detail.RepositoryName == "" {
+		return nil, fmt.Errorf("missing repository-name in ECR event")
+	}
+
+	parsed := &ParsedPayload{Repository: detail.RepositoryName, Tags: []string{detail.ImageTag}, Digest: detail.ImageDigest, Timestamp: envelope.Timestamp}
+	if detail.ActionType != "PUSH" || detail.Result != "SUCCESS" {
+		parsed.SkipReason = "ECR action " + detail.ActionType + " with result " + detail.Result + " is not a successful push"
+	}
+	return parsed, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// readTestdata returns a captured webhook body from testdata.
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestDockerHubParserImagesOnly(t *testing.T) {
+	body := []byte(`{"push_data":{"images":["myorg/app:1.2.0","myorg/app@sha256:abc","myorg/app:latest"]},"repository":{"repo_name":"myorg/app"}}`)
+	parsed, err := DockerHubParser{}.Parse(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1.2.0", "latest"}; !reflect.DeepEqual(parsed.Tags, want) {
+		t.Errorf("Tags = %v, want %v", parsed.Tags, want)
+	}
+}
+
+func TestQuayParser(t *testing.T) {
+	parsed, err := QuayParser{}.Parse(readTestdata(t, "quay_push.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Repository != "mynamespace/repository" {
+		t.Errorf("Repository = %q, want %q", parsed.Repository, "mynamespace/repository")
+	}
+	if want := []string{"latest", "1.2.0"}; !reflect.DeepEqual(parsed.Tags, want) {
+		t.Errorf("Tags = %v, want %v", parsed.Tags, want)
+	}
+}
+
+func TestQuayParserNamespaceAndName(t *testing.T) {
+	parsed, err := QuayParser{}.Parse([]byte(`{"namespace":"myorg","name":"app","updated_tags":["latest"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Repository != "myorg/app" {
+		t.Errorf("Repository = %q, want %q", parsed.Repository, "myorg/app")
+	}
+}
+
+func TestQuayParserInvalid(t *testing.T) {
+	for _, body := range []string{`{"updated_tags":["latest"]}`, `{"repository":"myorg/app","updated_tags":[]}`, `not json`} {
+		if _, err := (QuayParser{}).Parse([]byte(body)); err == nil {
+			t.Errorf("Parse(%s) succeeded, want an error", body)
+		}
+	}
+}
+
+func TestHarborParser(t *testing.T) {
+	parsed, err := HarborParser{}.Parse(readTestdata(t, "harbor_push_artifact.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Repository != "library/nginx" {
+		t.Errorf("Repository = %q, want %q", parsed.Repository, "library/nginx")
+	}
+	if want := []string{"latest"}; !reflect.DeepEqual(parsed.Tags, want) {
+		t.Errorf("Tags = %v, want %v", parsed.Tags, want)
+	}
+	if want := "sha256:954b378c375d852eb3c63ab88978f640b4348b01c1b3456a024a81536dafbbf4"; parsed.Digest != want {
+		t.Errorf("Digest = %q, want %q", parsed.Digest, want)
+	}
+}
+
+func TestHarborParserDelete(t *testing.T) {
+	body := `{"type":"DELETE_ARTIFACT","occur_at":1680502375,"operator":"admin","event_data":{"resources":[{"digest":"sha256:954b378c375d852eb3c63ab88978f640b4348b01c1b3456a024a81536dafbbf4","tag":"latest","resource_url":"harbor.example.com/library/nginx:latest"}],"repository":{"name":"nginx","namespace":"library","repo_full_name":"library/nginx","repo_type":"private"}}}`
+	parsed, err := HarborParser{}.Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse() = %v, want delete events skipped rather than rejected", err)
+	}
+	if parsed.SkipReason == "" {
+		t.Error("SkipReason is empty, want delete events skipped")
+	}
+	if parsed.Repository != "library/nginx" {
+		t.Errorf("Repository = %q, want %q", parsed.Repository, "library/nginx")
+	}
+}
+
+func TestHarborParserUntaggedPush(t *testing.T) {
+	body := `{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"digest":"sha256:abc"}],"repository":{"repo_full_name":"library/nginx"}}}`
+	if _, err := (HarborParser{}).Parse([]byte(body)); err == nil {
+		t.Error("Parse() succeeded without a tagged resource, want an error")
+	}
+}
+
+func TestGiteaParser(t *testing.T) {
+	parsed, err := GiteaParser{}.Parse(readTestdata(t, "gitea_package_created.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Repository != "myorg/app" {
+		t.Errorf("Repository = %q, want %q", parsed.Repository, "myorg/app")
+	}
+	if want := []string{"1.2.0"}; !reflect.DeepEqual(parsed.Tags, want) {
+		t.Errorf("Tags = %v, want %v", parsed.Tags, want)
+	}
+	if parsed.SkipReason != "" {
+		t.Errorf("SkipReason = %q, want a forward", parsed.SkipReason)
+	}
+}
+
+func TestGiteaParserActions(t *testing.T) {
+	tests := []struct {
+		action  string
+		forward bool
+	}{
+		{"published", true},
+		{"created", true},
+		{"deleted", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		body := `{"action":"` + tt.action + `","package":{"owner":{"login":"myorg"},"type":"container","name":"app","version":"latest"}}`
+		parsed, err := GiteaParser{}.Parse([]byte(body))
+		if err != nil {
+			t.Fatalf("Parse(%q action): %v", tt.action, err)
+		}
+		if forward := parsed.SkipReason == ""; forward != tt.forward {
+			t.Errorf("action %q: forward = %v (skip reason %q), want %v", tt.action, forward, parsed.SkipReason, tt.forward)
+		}
+	}
+}
+
+func TestGiteaParserMissingPackage(t *testing.T) {
+	if _, err := (GiteaParser{}).Parse([]byte(`{"action":"published"}`)); err == nil {
+		t.Error("Parse() succeeded without a package, want an error")
+	}
+}
+
+func TestACRParser(t *testing.T) {
+	parsed, err := ACRParser{}.Parse(readTestdata(t, "acr_push.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Repository != "hello-world" {
+		t.Errorf("Repository = %q, want %q", parsed.Repository, "hello-world")
+	}
+	if want := []string{"v1"}; !reflect.DeepEqual(parsed.Tags, want) {
+		t.Errorf("Tags = %v, want %v", parsed.Tags, want)
+	}
+	if want := "sha256:80f0d5c8786bb9e621a45ece0db56d11cdc624ad20da9fe62e9d25490f331d7d"; parsed.Digest != want {
+		t.Errorf("Digest = %q, want %q", parsed.Digest, want)
+	}
+	if want := time.Date(2017, 11, 17, 16, 52, 1, 343145347, time.UTC); !parsed.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", parsed.Timestamp, want)
+	}
+	if parsed.SkipReason != "" {
+		t.Errorf("SkipReason = %q, want a forward", parsed.SkipReason)
+	}
+}
+
+func TestACRParserDelete(t *testing.T) {
+	parsed, err := ACRParser{}.Parse([]byte(`{"action":"delete","target":{"repository":"hello-world","tag":"v1"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.SkipReason == "" {
+		t.Error("SkipReason is empty, want delete events skipped")
+	}
+}
+
+func TestACRParserMissingTarget(t *testing.T) {
+	for _, body := range []string{`{"action":"push"}`, `{"action":"push","target":{"tag":"v1"}}`} {
+		if _, err := (ACRParser{}).Parse([]byte(body)); err == nil {
+			t.Errorf("Parse(%s) succeeded, want an error", body)
+		}
+	}
+}
+
+func TestECRParserPush(t *testing.T) {
+	parsed, err := ECRParser{}.Parse(readTestdata(t, "sns_ecr_push.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Repository != "my-repo" {
+		t.Errorf("Repository = %q, want %q", parsed.Repository, "my-repo")
+	}
+	if want := []string{"latest"}; !reflect.DeepEqual(parsed.Tags, want) {
+		t.Errorf("Tags = %v, want %v", parsed.Tags, want)
+	}
+	if want := "sha256:7f5b2640fe6fb4f46592dfd3410c4a79dac4f89e4782432e0378abcd1234"; parsed.Digest != want {
+		t.Errorf("Digest = %q, want %q", parsed.Digest, want)
+	}
+	if parsed.SkipReason != "" || parsed.SubscribeURL != "" {
+		t.Errorf("SkipReason = %q, SubscribeURL = %q, want a forward", parsed.SkipReason, parsed.SubscribeURL)
+	}
+}
+
+func TestECRParserSubscriptionConfirmation(t *testing.T) {
+	parsed, err := ECRParser{}.Parse(readTestdata(t, "sns_subscription_confirmation.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://sns.us-west-2.amazonaws.com/?Action=ConfirmSubscription&TopicArn=arn:aws:sns:us-west-2:123456789012:ecr-image-events&Token=2336412f37"; parsed.SubscribeURL != want {
+		t.Errorf("SubscribeURL = %q, want %q", parsed.SubscribeURL, want)
+	}
+	if parsed.Repository != "" {
+		t.Errorf("Repository = %q, want none for a confirmation", parsed.Repository)
+	}
+}
+
+func TestECRParserFailedPush(t *testing.T) {
+	body := `{"Type":"Notification","Message":"{\"detail\":{\"result\":\"FAILURE\",\"repository-name\":\"my-repo\",\"action-type\":\"PUSH\",\"image-tag\":\"latest\"}}"}`
+	parsed, err := ECRParser{}.Parse([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.SkipReason == "" {
+		t.Error("SkipReason is empty, want failed pushes skipped")
+	}
+}
+
+func TestGitLabParserPush(t *testing.T) {
+	body := `{"events":[{"action":"pull","target":{"repository":"mygroup/app","tag":"latest"}},{"action":"push","target":{"repository":"mygroup/app","tag":"1.2.0","digest":"sha256:abc"}}]}`
+	parsed, err := GitLabParser{}.Parse([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Repository != "mygroup/app" || !reflect.DeepEqual(parsed.Tags, []string{"1.2.0"}) || parsed.Digest != "sha256:abc" {
+		t.Errorf("Parse() = %+v, want the push of mygroup/app:1.2.0", parsed)
+	}
+	if parsed.SkipReason != "" {
+		t.Errorf("SkipReason = %q, want a forward", parsed.SkipReason)
+	}
+}
+
+func TestGitLabParserPullOnly(t *testing.T) {
+	body := `{"events":[{"id":"asdf-asdf-asdf-asdf-0","timestamp":"2024-05-14T09:21:43Z","action":"pull","target":{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","digest":"sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf","repository":"mygroup/app","tag":"latest"}}]}`
+	parsed, err := GitLabParser{}.Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse() = %v, want pulls skipped rather than rejected", err)
+	}
+	if parsed.SkipReason == "" {
+		t.Error("SkipReason is empty, want pull notifications skipped")
+	}
+	if parsed.Repository != "mygroup/app" {
+		t.Errorf("Repository = %q, want %q", parsed.Repository, "mygroup/app")
+	}
+}
+
+func TestFiltersEvents(t *testing.T) {
+	for _, parser := range []PayloadParser{GitLabParser{}, HarborParser{}, GiteaParser{}, ACRParser{}, ECRParser{}} {
+		if !FiltersEvents(parser) {
+			t.Errorf("FiltersEvents(%T) = false, want true", parser)
+		}
+	}
+	if FiltersEvents(DockerHubParser{}) {
+		t.Error("FiltersEvents(DockerHubParser) = true, want false")
+	}
+}
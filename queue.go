@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QueuedForward is a pending forward persisted until it has been processed.
+type QueuedForward struct {
+	Key       string    `json:"key"`
+	ID        string    `json:"id"`
+	Webhook   *Webhook  `json:"webhook"`
+	Targets   []string  `json:"targets"`
+	ForwardAt time.Time `json:"forward_at"`
+}
+
+// PersistentQueue stores pending forwards in a JSON file so that they survive
+// restarts. Entries are keyed so that a newer forward (e.g. a debounced push)
+// replaces the previous one.
+type PersistentQueue struct {
+	Path string
+
+	mu      sync.Mutex
+	entries map[string]QueuedForward
+}
+
+// OpenPersistentQueue loads the queue stored at path, starting empty when the
+// file does not exist yet.
+func OpenPersistentQueue(path string) (*PersistentQueue, error) {
+	q := &PersistentQueue{Path: path, entries: make(map[string]QueuedForward)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []QueuedForward
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		q.entries[entry.Key] = entry
+	}
+	return q, nil
+}
+
+// Add persists entry, replacing any entry with the same key.
+func (q *PersistentQueue) Add(entry QueuedForward) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.entries[entry.Key] = entry
+	return q.save()
+}
+
+// Remove deletes the entry for key, unless it has since been replaced by an
+// entry with a different ID.
+func (q *PersistentQueue) Remove(key, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if entry, ok := q.entries[key]; !ok || entry.ID != id {
+		return nil
+	}
+	delete(q.entries, key)
+	return q.save()
+}
+
+// Entries returns the outstanding entries, oldest forward first.
+func (q *PersistentQueue) Entries() []QueuedForward {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.sorted()
+}
+
+func (q *PersistentQueue) sorted() []QueuedForward {
+	entries := make([]QueuedForward, 0, len(q.entries))
+	for _, entry := range q.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ForwardAt.Before(entries[j].ForwardAt)
+	})
+	return entries
+}
+
+// save rewrites the queue file atomically so a crash never leaves it truncated.
+func (q *PersistentQueue) save() error {
+	data, err := json.Marshal(q.sorted())
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(q.Path), filepath.Base(q.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), q.Path)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistentQueueKeepsEntriesAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q, err := OpenPersistentQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := &Webhook{ID: "id", Repository: "myorg/app", Tag: "latest"}
+	for _, entry := range []QueuedForward{
+		{Key: "failed", ID: "1", Webhook: hook, Targets: []string{"http://watchtower:8080"}, ForwardAt: time.Unix(100, 0)},
+		{Key: "succeeded", ID: "2", Webhook: hook, Targets: []string{"http://watchtower:8080"}, ForwardAt: time.Unix(200, 0)},
+	} {
+		if err := q.Add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Remove("succeeded", "2"); err != nil {
+		t.Fatal(err)
+	}
+	// A replaced entry is only removed by its own forward
+	if err := q.Remove("failed", "stale"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenPersistentQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := reopened.Entries()
+	if len(entries) != 1 || entries[0].Key != "failed" || entries[0].Webhook.Repository != "myorg/app" {
+		t.Errorf("Entries() = %+v, want only the failed forward", entries)
+	}
+}
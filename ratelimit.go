@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter applies a token bucket per webhook ID.
+type RateLimiter struct {
+	perMinute int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewRateLimiter allows perMinute requests per ID, with bursts of the same
+// size.
+func NewRateLimiter(perMinute int) *RateLimiter {
+	return &RateLimiter{
+		perMinute: perMinute,
+		limiters:  make(map[string]*rate.Limiter),
+	}
+}
+
+// Allow reports whether a request for the ID may proceed.
+func (l *RateLimiter) Allow(id string) bool {
+	l.mu.Lock()
+	limiter, ok := l.limiters[id]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(l.perMinute)), l.perMinute)
+		l.limiters[id] = limiter
+	}
+	l.mu.Unlock()
+
+	return limiter.Allow()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// readinessCacheTTL is how long a readiness result is reused before
+// Watchtower is probed again.
+const readinessCacheTTL = 5 * time.Second
+
+// ReadinessChecker probes the Watchtower targets and caches the result.
+type ReadinessChecker struct {
+	Targets []string
+	Client  *http.Client
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// Check returns nil when every target answered an HTTP request. Any HTTP
+// response counts as reachable, since the base URL usually isn't routed.
+func (c *ReadinessChecker) Check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < readinessCacheTTL {
+		return c.err
+	}
+
+	c.err = nil
+	for _, target := range c.Targets {
+		if err := c.probe(ctx, target); err != nil {
+			c.err = err
+			break
+		}
+	}
+	c.checkedAt = time.Now()
+	return c.err
+}
+
+// SetTargets replaces the probed targets and drops the cached result.
+func (c *ReadinessChecker) SetTargets(targets []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Targets = targets
+	c.checkedAt = time.Time{}
+}
+
+func (c *ReadinessChecker) probe(ctx context.Context, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return fmt.Errorf("watchtower %s: %w", target, err)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("watchtower %s unreachable: %w", target, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// StartupCheck sends a GET request to each Watchtower target once and logs
+// whether it answered. Unreachable targets are only reported as warnings.
+func StartupCheck(ctx context.Context, client *http.Client, targets []string) {
+	slices.Sort(targets)
+	for _, target := range slices.Compact(targets) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			slog.Warn("Startup check failed", "url", target, "error", err)
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			slog.Warn("Watchtower is unreachable at startup", "url", target, "error", err)
+			continue
+		}
+		resp.Body.Close()
+		slog.Info("Watchtower is reachable", "url", target, "status", resp.StatusCode)
+	}
+}
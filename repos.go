@@ -0,0 +1,29 @@
+package main
+
+import "slices"
+
+// RepoFilter restricts which repositories may trigger updates. Entries are
+// repository names or globs (e.g. "myorg/*"). Denied repositories take
+// precedence over allowed ones, and an empty allow list permits every
+// repository.
+type RepoFilter struct {
+	Allowed []string
+	Denied  []string
+}
+
+// Enabled reports whether any repository rule is configured.
+func (f *RepoFilter) Enabled() bool {
+	return len(f.Allowed) > 0 || len(f.Denied) > 0
+}
+
+// Permit reports whether repo may be forwarded, with the reason when not.
+func (f *RepoFilter) Permit(repo string) (bool, string) {
+	match := func(pattern string) bool { return matchRepo(pattern, repo) }
+	if slices.ContainsFunc(f.Denied, match) {
+		return false, "repository is denied"
+	}
+	if len(f.Allowed) > 0 && !slices.ContainsFunc(f.Allowed, match) {
+		return false, "repository is not allowed"
+	}
+	return true, ""
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestRepoFilterPermit(t *testing.T) {
+	filter := &RepoFilter{
+		Allowed: []string{"myorg/*", "*/api"},
+		Denied:  []string{"myorg/legacy"},
+	}
+	tests := []struct {
+		repo string
+		want bool
+	}{
+		{"myorg/web", true},
+		{"otherorg/api", true},
+		{"myorg/legacy", false},
+		{"otherorg/web", false},
+		{"myorg/team/web", false},
+	}
+	for _, tt := range tests {
+		if got, reason := filter.Permit(tt.repo); got != tt.want {
+			t.Errorf("Permit(%q) = %v (%s), want %v", tt.repo, got, reason, tt.want)
+		}
+	}
+}
+
+func TestRepoFilterPermitDenyOnly(t *testing.T) {
+	filter := &RepoFilter{Denied: []string{"*/api"}}
+	if ok, _ := filter.Permit("myorg/web"); !ok {
+		t.Error("Permit(myorg/web) = false, want repositories allowed without an allow list")
+	}
+	if ok, reason := filter.Permit("myorg/api"); ok || reason != "repository is denied" {
+		t.Errorf("Permit(myorg/api) = %v, %q, want denied", ok, reason)
+	}
+}
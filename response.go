@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// writeJSON writes v as a JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error body of the form {"error":"...","code":N}.
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+	}{message, code})
+}
+
+// Endpoints lists the routes registered on router as "METHOD /path".
+func Endpoints(router *mux.Router) []string {
+	var endpoints []string
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// Subrouter prefixes carry no methods and are not endpoints themselves
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		endpoints = append(endpoints, strings.Join(methods, ",")+" "+path)
+		return nil
+	})
+	return endpoints
+}
+
+// allowedMethods returns the methods that router accepts for the path of req.
+func allowedMethods(router *mux.Router, req *http.Request) []string {
+	var allowed []string
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			probe := req.Clone(req.Context())
+			probe.Method = method
+			var match mux.RouteMatch
+			if route.Match(probe, &match) && match.MatchErr == nil && !slices.Contains(allowed, method) {
+				allowed = append(allowed, method)
+			}
+		}
+		return nil
+	})
+	return allowed
+}
+
+// UnmatchedHandler answers requests no route accepts with a JSON error
+// listing the valid endpoints: 405 with an Allow header when the path exists
+// for other methods, 404 otherwise. It checks the methods itself because a
+// BASE_PATH subrouter does not reliably report method mismatches.
+func UnmatchedHandler(router *mux.Router) http.Handler {
+	endpoints := Endpoints(router)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, message := http.StatusNotFound, "Not found"
+		if allowed := allowedMethods(router, r); len(allowed) > 0 {
+			code, message = http.StatusMethodNotAllowed, "Method not allowed"
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(struct {
+			Error     string   `json:"error"`
+			Code      int      `json:"code"`
+			Endpoints []string `json:"endpoints"`
+		}{message, code, endpoints})
+	})
+}
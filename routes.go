@@ -0,0 +1,93 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Route is the Watchtower target of a repository, with an optional API key
+// replacing WATCHTOWER_API_KEY for that target.
+type Route struct {
+	Target string `json:"url" yaml:"url"`
+	APIKey string `json:"-" yaml:"api_key"`
+}
+
+// UnmarshalYAML accepts either a plain URL or a mapping with url and api_key.
+func (r *Route) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&r.Target)
+	}
+	type plain Route
+	return node.Decode((*plain)(r))
+}
+
+// Routes maps a repository name (e.g. "myorg/api") to the Watchtower that
+// should receive its webhooks.
+type Routes map[string]Route
+
+// ParseRoutes parses a comma-separated list of repo=url pairs, where the url
+// may be followed by |apikey.
+func ParseRoutes(value string) (Routes, error) {
+	routes := make(Routes)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		repo, target, ok := strings.Cut(entry, "=")
+		target, apiKey, _ := strings.Cut(target, "|")
+		repo, target = strings.TrimSpace(repo), strings.TrimSpace(target)
+		if !ok || repo == "" || target == "" {
+			return nil, fmt.Errorf("invalid route for %q, expected repo=url or repo=url|apikey", repo)
+		}
+		routes[repo] = Route{Target: target, APIKey: strings.TrimSpace(apiKey)}
+	}
+	return routes, nil
+}
+
+// Targets returns the Watchtower targets for a repository, falling back to
+// the default targets when no route matches. An exact route wins over glob
+// routes, and longer glob patterns win over shorter ones.
+func (r Routes) Targets(repo string, fallback []string) []string {
+	if route, ok := r[repo]; ok {
+		return []string{route.Target}
+	}
+
+	patterns := make([]string, 0, len(r))
+	for pattern := range r {
+		patterns = append(patterns, pattern)
+	}
+	slices.SortFunc(patterns, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), cmp.Compare(a, b))
+	})
+	for _, pattern := range patterns {
+		if matchRepo(pattern, repo) {
+			return []string{r[pattern].Target}
+		}
+	}
+	return fallback
+}
+
+// matchRepo reports whether repo matches pattern using path.Match syntax:
+// "*" matches any run of characters except "/", "?" a single character and
+// "[...]" a character class.
+func matchRepo(pattern, repo string) bool {
+	matched, err := path.Match(pattern, repo)
+	return err == nil && matched
+}
+
+// APIKeyFor returns the API key of the route targeting target, falling back
+// to the global key when no route defines one.
+func (r Routes) APIKeyFor(target, fallback string) string {
+	for _, route := range r {
+		if route.Target == target && route.APIKey != "" {
+			return route.APIKey
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" myorg/api=http://watchtower-a:8080 , myorg/web=http://watchtower-b:8080|secret,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Routes{
+		"myorg/api": {Target: "http://watchtower-a:8080"},
+		"myorg/web": {Target: "http://watchtower-b:8080", APIKey: "secret"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("ParseRoutes() = %v, want %v", routes, want)
+	}
+}
+
+func TestParseRoutesInvalid(t *testing.T) {
+	for _, value := range []string{"myorg/api", "=http://watchtower:8080", "myorg/api=", "myorg/api=|secret"} {
+		if _, err := ParseRoutes(value); err == nil {
+			t.Errorf("ParseRoutes(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestRoutesTargets(t *testing.T) {
+	routes := Routes{
+		"myorg/api": {Target: "http://watchtower-a:8080"},
+		"myorg/web": {Target: "http://watchtower-b:8080"},
+	}
+	fallback := []string{"http://watchtower:8080", "http://watchtower-2:8080"}
+
+	tests := []struct {
+		repo string
+		want []string
+	}{
+		{"myorg/api", []string{"http://watchtower-a:8080"}},
+		{"myorg/web", []string{"http://watchtower-b:8080"}},
+		{"myorg/worker", fallback},
+		{"", fallback},
+	}
+	for _, tt := range tests {
+		if got := routes.Targets(tt.repo, fallback); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Targets(%q) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestRoutesTargetsWithoutRoutes(t *testing.T) {
+	fallback := []string{"http://watchtower:8080"}
+	if got := Routes(nil).Targets("myorg/api", fallback); !reflect.DeepEqual(got, fallback) {
+		t.Errorf("Targets() = %v, want the WATCHTOWER_URL targets %v", got, fallback)
+	}
+}
+
+func TestMatchRepo(t *testing.T) {
+	tests := []struct {
+		pattern string
+		repo    string
+		want    bool
+	}{
+		{"myorg/*", "myorg/api", true},
+		{"myorg/*", "myorg/web", true},
+		{"myorg/*", "otherorg/api", false},
+		{"myorg/*", "myorg/team/api", false},
+		{"*/api", "myorg/api", true},
+		{"*/api", "otherorg/api", true},
+		{"*/api", "myorg/api-gateway", false},
+		{"myorg/api", "myorg/api", true},
+		{"[", "myorg/api", false},
+	}
+	for _, tt := range tests {
+		if got := matchRepo(tt.pattern, tt.repo); got != tt.want {
+			t.Errorf("matchRepo(%q, %q) = %v, want %v", tt.pattern, tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestRoutesTargetsGlob(t *testing.T) {
+	routes := Routes{
+		"myorg/*":     {Target: "http://watchtower-myorg:8080"},
+		"*/api":       {Target: "http://watchtower-api:8080"},
+		"myorg/web":   {Target: "http://watchtower-web:8080"},
+		"myorg/api-*": {Target: "http://watchtower-api-variants:8080"},
+	}
+	fallback := []string{"http://watchtower:8080"}
+
+	tests := []struct {
+		repo string
+		want string
+	}{
+		// Exact routes win over globs
+		{"myorg/web", "http://watchtower-web:8080"},
+		// Longer patterns win over shorter ones
+		{"myorg/api-gateway", "http://watchtower-api-variants:8080"},
+		{"myorg/worker", "http://watchtower-myorg:8080"},
+		{"otherorg/api", "http://watchtower-api:8080"},
+		{"otherorg/web", "http://watchtower:8080"},
+	}
+	for _, tt := range tests {
+		if got := routes.Targets(tt.repo, fallback); !reflect.DeepEqual(got, []string{tt.want}) {
+			t.Errorf("Targets(%q) = %v, want [%s]", tt.repo, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Settings holds the values derived from a Config. They are swapped as a
+// whole when the configuration is reloaded.
+type Settings struct {
+	Config         *Config
+	WebhookIDs     map[string]bool
+	APIKey         string
+	WatchtowerURLs []string
+	Routes         Routes
+	TagFilter      *TagFilter
+	// WatchOnly is set when only tags matching TagFilter are forwarded.
+	WatchOnly bool
+}
+
+// NewSettings validates cfg and derives the runtime settings from it. The tag
+// options come from environment variables that are not part of Config.
+func NewSettings(cfg *Config, tagCaseInsensitive bool, ignoreTagPatterns []string) (*Settings, error) {
+	s := &Settings{
+		Config:     cfg,
+		WebhookIDs: make(map[string]bool),
+		APIKey:     cfg.APIKey,
+		WatchOnly:  cfg.WatchOnlyForLatestTag,
+	}
+	if s.WatchOnly {
+		slog.Debug("Watch only for latest tag is ENABLED")
+	} else {
+		slog.Debug("Watch only for latest tag is DISABLED - all tags will trigger updates")
+	}
+
+	// Build the tag rules (watched tags take precedence over WATCH_ONLY_FOR_LATEST_TAG)
+	s.TagFilter = &TagFilter{Tags: cfg.WatchTags, CaseInsensitive: tagCaseInsensitive, Ignore: ignoreTagPatterns}
+	if tagCaseInsensitive {
+		slog.Debug("Tags are matched case-insensitively")
+	}
+	if cfg.WatchTagRegex != "" {
+		expr := cfg.WatchTagRegex
+		if tagCaseInsensitive {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid watch_tag_regex (WATCH_TAG_REGEX) %q: %w", cfg.WatchTagRegex, err)
+		}
+		s.TagFilter.Regex = re
+		slog.Debug("Watching tags matching regex", "regex", re.String())
+	}
+	if cfg.WatchTagSemver != "" {
+		constraints, err := semver.NewConstraint(cfg.WatchTagSemver)
+		if err != nil {
+			return nil, fmt.Errorf("invalid watch_tag_semver (WATCH_TAG_SEMVER) %q: %w", cfg.WatchTagSemver, err)
+		}
+		s.TagFilter.Semver = constraints
+		slog.Debug("Watching tags satisfying semver constraint", "constraint", constraints.String())
+	}
+	if s.TagFilter.Enabled() {
+		s.WatchOnly = true
+		if len(cfg.WatchTags) > 0 {
+			slog.Debug("Watching tags", "tags", strings.Join(cfg.WatchTags, ","))
+		}
+	} else if s.WatchOnly {
+		s.TagFilter.Tags = []string{"latest"}
+	}
+	if len(ignoreTagPatterns) > 0 {
+		slog.Debug("Ignoring tags matching patterns", "patterns", strings.Join(ignoreTagPatterns, ","))
+	}
+	slog.Debug("Delay before forwarding webhook", "seconds", cfg.DelaySeconds)
+
+	s.WatchtowerURLs = cfg.WatchtowerURLs
+	if len(s.WatchtowerURLs) == 0 {
+		slog.Info("WATCHTOWER_URL not set, defaulting to http://localhost:8080")
+		s.WatchtowerURLs = []string{"http://localhost:8080"}
+	}
+	for i, raw := range s.WatchtowerURLs {
+		normalized, err := NormalizeWatchtowerURL(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid watchtower_urls (WATCHTOWER_URL) %q: %w", raw, err)
+		}
+		s.WatchtowerURLs[i] = normalized
+	}
+	slog.Info("Using WATCHTOWER_URL", "url", strings.Join(s.WatchtowerURLs, ","))
+	slog.Debug("Forwarding to Watchtower targets", "count", len(s.WatchtowerURLs), "targets", strings.Join(s.WatchtowerURLs, ","))
+
+	// Per-repository routes
+	s.Routes = cfg.Routes
+	for repo, route := range s.Routes {
+		normalized, err := NormalizeWatchtowerURL(route.Target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route URL %q for repository %s: %w", route.Target, repo, err)
+		}
+		route.Target = normalized
+		s.Routes[repo] = route
+		slog.Debug("Routing repository", "repository", repo, "target", route.Target, "api_key", route.APIKey != "")
+	}
+
+	// Build the set of accepted webhook IDs
+	for _, id := range cfg.WebhookIDs {
+		s.WebhookIDs[strings.TrimSpace(id)] = true
+	}
+	return s, nil
+}
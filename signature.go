@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of the request body.
+const SignatureHeader = "X-Hub-Signature-256"
+
+// SignatureSHA1Header carries the legacy HMAC-SHA1 signature sent by GitHub.
+const SignatureSHA1Header = "X-Hub-Signature"
+
+// GitLabTokenHeader carries the secret token configured on a GitLab webhook.
+const GitLabTokenHeader = "X-Gitlab-Token"
+
+// Supported values for SIGNATURE_ALGO.
+const (
+	SignatureSHA1   = "sha1"
+	SignatureSHA256 = "sha256"
+)
+
+// ParseSignatureAlgo validates a SIGNATURE_ALGO value, defaulting to sha256.
+func ParseSignatureAlgo(value string) (string, error) {
+	switch value = strings.ToLower(value); value {
+	case "":
+		return SignatureSHA256, nil
+	case SignatureSHA1, SignatureSHA256:
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported SIGNATURE_ALGO value %q, expected sha1 or sha256", value)
+	}
+}
+
+// DefaultSignatureHeader returns the header GitHub uses for the algorithm.
+func DefaultSignatureHeader(algo string) string {
+	if algo == SignatureSHA1 {
+		return SignatureSHA1Header
+	}
+	return SignatureHeader
+}
+
+// VerifySignature reports whether signature is the hex-encoded HMAC of body
+// using secret and algo (sha1 or sha256). An optional "<algo>=" prefix, as
+// sent by GitHub, is accepted.
+func VerifySignature(secret, algo string, body []byte, signature string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), algo+"=")
+	received, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	newHash := sha256.New
+	if algo == SignatureSHA1 {
+		newHash = func() hash.Hash { return sha1.New() }
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(received, mac.Sum(nil))
+}
+
+// VerifyToken compares a received shared token with the expected one in
+// constant time.
+func VerifyToken(expected, received string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(received)) == 1
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ConfirmSNSSubscription confirms an SNS subscription by visiting its
+// SubscribeURL. Only HTTPS URLs on amazonaws.com are visited, so a forged
+// confirmation can't make the proxy request arbitrary URLs.
+func ConfirmSNSSubscription(ctx context.Context, client *http.Client, subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return fmt.Errorf("refusing to confirm subscription at untrusted URL %q", subscribeURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("subscription confirmation returned status %d", resp.StatusCode)
+	}
+	return nil
+}
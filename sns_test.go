@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// snsTestClient sends every request to server, whatever the URL host.
+func snsTestClient(server *httptest.Server) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
+
+func TestConfirmSNSSubscription(t *testing.T) {
+	var query string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+	}))
+	defer server.Close()
+
+	err := ConfirmSNSSubscription(context.Background(), snsTestClient(server), "https://sns.us-west-2.amazonaws.com/?Action=ConfirmSubscription&Token=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Action=ConfirmSubscription&Token=abc"; query != want {
+		t.Errorf("confirmation query = %q, want %q", query, want)
+	}
+}
+
+func TestConfirmSNSSubscriptionRejected(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if err := ConfirmSNSSubscription(context.Background(), snsTestClient(server), "https://sns.us-west-2.amazonaws.com/?Action=ConfirmSubscription"); err == nil {
+		t.Error("ConfirmSNSSubscription() succeeded on a 403, want an error")
+	}
+}
+
+func TestConfirmSNSSubscriptionUntrustedURL(t *testing.T) {
+	requested := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	for _, subscribeURL := range []string{
+		"http://sns.us-west-2.amazonaws.com/?Action=ConfirmSubscription",
+		"https://attacker.example.com/?Action=ConfirmSubscription",
+		"https://amazonaws.com.attacker.example.com/",
+	} {
+		if err := ConfirmSNSSubscription(context.Background(), snsTestClient(server), subscribeURL); err == nil {
+			t.Errorf("ConfirmSNSSubscription(%q) succeeded, want an error", subscribeURL)
+		}
+	}
+	if requested {
+		t.Error("an untrusted SubscribeURL was requested")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats keeps lightweight counters since startup for /api/stats, as an
+// alternative to Prometheus.
+type Stats struct {
+	StartedAt time.Time
+
+	received  atomic.Int64
+	forwarded atomic.Int64
+	skipped   atomic.Int64
+	coalesced atomic.Int64
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	rejected  atomic.Int64
+
+	mu        sync.Mutex
+	responses map[string]int64
+}
+
+// stats holds the process-wide counters.
+var stats = &Stats{StartedAt: time.Now()}
+
+// Record counts a webhook outcome.
+func (s *Stats) Record(outcome string) {
+	switch outcome {
+	case OutcomeReceived:
+		s.received.Add(1)
+	case OutcomeSkipped:
+		s.skipped.Add(1)
+	case OutcomeCoalesced:
+		s.coalesced.Add(1)
+	case OutcomeForwarded:
+		s.succeeded.Add(1)
+	case OutcomeFailed:
+		s.failed.Add(1)
+	case OutcomeRejected:
+		s.rejected.Add(1)
+	}
+}
+
+// RecordResponse counts a Watchtower response by category.
+func (s *Stats) RecordResponse(category string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.responses == nil {
+		s.responses = make(map[string]int64)
+	}
+	s.responses[category]++
+}
+
+// Accepted counts a webhook queued for forwarding.
+func (s *Stats) Accepted() {
+	s.forwarded.Add(1)
+}
+
+// StatsSnapshot is the JSON representation of Stats.
+type StatsSnapshot struct {
+	StartedAt         time.Time `json:"started_at"`
+	UptimeSeconds     int64     `json:"uptime_seconds"`
+	Received          int64     `json:"received"`
+	Forwarded         int64     `json:"forwarded"`
+	Skipped           int64     `json:"skipped"`
+	Coalesced         int64     `json:"coalesced"`
+	Succeeded         int64     `json:"succeeded"`
+	Failed            int64     `json:"failed"`
+	Rejected          int64     `json:"rejected"`
+	SuccessPercentage float64   `json:"success_percentage"`
+	// PendingForwards counts scheduled forwards that have not finished.
+	PendingForwards int64 `json:"pending_forwards"`
+	// Responses counts Watchtower responses by category.
+	Responses map[string]int64 `json:"responses"`
+	// Targets reports the health of each target when TARGET_FAILURE_THRESHOLD
+	// is set.
+	Targets map[string]TargetStatus `json:"targets,omitempty"`
+}
+
+// Snapshot returns the current counters. The success percentage covers the
+// final outcome of each forwarded webhook, after retries and fallback, and is
+// 0 until the first one.
+func (s *Stats) Snapshot() StatsSnapshot {
+	snapshot := StatsSnapshot{
+		StartedAt:     s.StartedAt,
+		UptimeSeconds: int64(time.Since(s.StartedAt).Seconds()),
+		Received:      s.received.Load(),
+		Forwarded:     s.forwarded.Load(),
+		Skipped:       s.skipped.Load(),
+		Coalesced:     s.coalesced.Load(),
+		Succeeded:     s.succeeded.Load(),
+		Failed:        s.failed.Load(),
+		Rejected:      s.rejected.Load(),
+	}
+	s.mu.Lock()
+	snapshot.Responses = maps.Clone(s.responses)
+	s.mu.Unlock()
+	if snapshot.Responses == nil {
+		snapshot.Responses = make(map[string]int64)
+	}
+	if total := snapshot.Succeeded + snapshot.Failed; total > 0 {
+		snapshot.SuccessPercentage = float64(snapshot.Succeeded) * 100 / float64(total)
+	}
+	return snapshot
+}
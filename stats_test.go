@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestStatsSuccessPercentageIgnoresRejected(t *testing.T) {
+	s := &Stats{}
+	for _, outcome := range []string{OutcomeForwarded, OutcomeForwarded, OutcomeForwarded, OutcomeFailed, OutcomeRejected, OutcomeRejected} {
+		s.Record(outcome)
+	}
+
+	snapshot := s.Snapshot()
+	if snapshot.Succeeded != 3 || snapshot.Failed != 1 || snapshot.Rejected != 2 {
+		t.Errorf("succeeded, failed, rejected = %d, %d, %d, want 3, 1, 2", snapshot.Succeeded, snapshot.Failed, snapshot.Rejected)
+	}
+	if snapshot.SuccessPercentage != 75 {
+		t.Errorf("SuccessPercentage = %v, want 75", snapshot.SuccessPercentage)
+	}
+}
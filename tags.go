@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// TagFilter decides which pushed tags should be forwarded to Watchtower.
+// Rules are compiled once at startup and shared by all requests.
+type TagFilter struct {
+	Tags   []string
+	Regex  *regexp.Regexp
+	Semver *semver.Constraints
+	// CaseInsensitive compares watched tags ignoring case.
+	CaseInsensitive bool
+	// Ignore lists glob patterns of tags that are never forwarded.
+	Ignore []string
+}
+
+// Enabled reports whether any tag rule is configured.
+func (f *TagFilter) Enabled() bool {
+	return len(f.Tags) > 0 || f.Regex != nil || f.Semver != nil
+}
+
+// Match reports whether the tag satisfies at least one configured rule.
+func (f *TagFilter) Match(tag string) bool {
+	if f.CaseInsensitive {
+		normalized := strings.ToLower(tag)
+		if slices.ContainsFunc(f.Tags, func(watched string) bool { return strings.ToLower(watched) == normalized }) {
+			slog.Debug("Tag matched watched tags ignoring case", "tag", tag, "normalized", normalized, "watched_tags", strings.ToLower(strings.Join(f.Tags, ",")))
+			return true
+		}
+	} else if slices.Contains(f.Tags, tag) {
+		slog.Debug("Tag matched watched tags", "tag", tag, "watched_tags", strings.Join(f.Tags, ","))
+		return true
+	}
+	if f.Regex != nil {
+		if f.Regex.MatchString(tag) {
+			slog.Debug("Tag matched regex", "tag", tag, "regex", f.Regex.String())
+			return true
+		}
+		slog.Debug("Tag did not match regex", "tag", tag, "regex", f.Regex.String())
+	}
+	if f.Semver != nil {
+		// Tags that are not valid semantic versions never match
+		version, err := semver.NewVersion(tag)
+		if err != nil {
+			slog.Debug("Tag is not a semantic version", "tag", tag, "error", err)
+		} else if f.Semver.Check(version) {
+			slog.Debug("Tag satisfies semver constraint", "tag", tag, "constraint", f.Semver.String())
+			return true
+		} else {
+			slog.Debug("Tag does not satisfy semver constraint", "tag", tag, "constraint", f.Semver.String())
+		}
+	}
+	return false
+}
+
+// Ignored reports whether the tag matches one of the ignore patterns.
+func (f *TagFilter) Ignored(tag string) bool {
+	for _, pattern := range f.Ignore {
+		candidate := tag
+		if f.CaseInsensitive {
+			pattern, candidate = strings.ToLower(pattern), strings.ToLower(tag)
+		}
+		if ok, _ := path.Match(pattern, candidate); ok {
+			slog.Debug("Tag matched ignore pattern", "tag", tag, "pattern", pattern)
+			return true
+		}
+	}
+	return false
+}
+
+// Unignored returns the tags that don't match any ignore pattern.
+func (f *TagFilter) Unignored(tags []string) []string {
+	return slices.DeleteFunc(slices.Clone(tags), f.Ignored)
+}
+
+// MatchAny returns the first tag satisfying the rules, if any.
+func (f *TagFilter) MatchAny(tags []string) (string, bool) {
+	for _, tag := range tags {
+		if f.Match(tag) {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// Describe returns a human-readable summary of the expected tags.
+func (f *TagFilter) Describe() string {
+	var rules []string
+	if len(f.Tags) > 0 {
+		rules = append(rules, strings.Join(f.Tags, " or "))
+	}
+	if f.Regex != nil {
+		rules = append(rules, "matching "+f.Regex.String())
+	}
+	if f.Semver != nil {
+		rules = append(rules, "satisfying "+f.Semver.String())
+	}
+	return strings.Join(rules, " or ")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+func TestTagFilterMatchSemver(t *testing.T) {
+	constraint, err := semver.NewConstraint(">=1.2.0, <2.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := &TagFilter{Semver: constraint}
+
+	tests := []struct {
+		tag  string
+		want bool
+	}{
+		{"1.2.0", true},
+		{"v1.4.7", true},
+		{"1.9", true},
+		{"1.1.9", false},
+		{"2.0.0", false},
+		{"2.0.0-rc.1", false},
+		{"latest", false},
+		{"main-abc123", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := filter.Match(tt.tag); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestTagFilterIgnored(t *testing.T) {
+	filter := &TagFilter{Ignore: []string{"*-rc*", "sha-*", "dev"}}
+	tests := []struct {
+		tag  string
+		want bool
+	}{
+		{"1.2.0-rc1", true},
+		{"2.0.0-rc.2", true},
+		{"sha-abc123", true},
+		{"dev", true},
+		{"develop", false},
+		{"1.2.0", false},
+		{"latest", false},
+		{"SHA-abc123", false},
+	}
+	for _, tt := range tests {
+		if got := filter.Ignored(tt.tag); got != tt.want {
+			t.Errorf("Ignored(%q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestTagFilterIgnoredCaseInsensitive(t *testing.T) {
+	filter := &TagFilter{Ignore: []string{"sha-*"}, CaseInsensitive: true}
+	if !filter.Ignored("SHA-abc123") {
+		t.Error("Ignored(SHA-abc123) = false, want true ignoring case")
+	}
+}
+
+func TestTagFilterUnignored(t *testing.T) {
+	filter := &TagFilter{Ignore: []string{"*-rc*"}}
+	tags := []string{"1.2.0", "1.3.0-rc1", "latest"}
+	if got, want := filter.Unignored(tags), []string{"1.2.0", "latest"}; !slices.Equal(got, want) {
+		t.Errorf("Unignored() = %v, want %v", got, want)
+	}
+	if len(tags) != 3 {
+		t.Errorf("Unignored() modified its argument: %v", tags)
+	}
+}
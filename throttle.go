@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle enforces a minimum interval between forwards to the same target.
+type Throttle struct {
+	MinInterval time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// NewThrottle creates a Throttle with the given minimum interval.
+func NewThrottle(minInterval time.Duration) *Throttle {
+	return &Throttle{
+		MinInterval: minInterval,
+		last:        make(map[string]time.Time),
+	}
+}
+
+// Allow reports whether a forward to target may proceed now, and records it
+// as the latest forward if so.
+func (t *Throttle) Allow(target string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := t.last[target]; ok && now.Sub(last) < t.MinInterval {
+		return false
+	}
+	t.last[target] = now
+	return true
+}
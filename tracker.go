@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ForwardTracker keeps track of pending asynchronous forwards so that they
+// can be drained on shutdown.
+type ForwardTracker struct {
+	wg      sync.WaitGroup
+	pending atomic.Int64
+}
+
+// Add registers a new pending forward.
+func (t *ForwardTracker) Add() {
+	pendingForwards.Inc()
+	t.pending.Add(1)
+	t.wg.Add(1)
+}
+
+// Done marks a pending forward as finished.
+func (t *ForwardTracker) Done() {
+	pendingForwards.Dec()
+	t.pending.Add(-1)
+	t.wg.Done()
+}
+
+// Pending returns the number of forwards that have not finished yet.
+func (t *ForwardTracker) Pending() int64 {
+	return t.pending.Load()
+}
+
+// Wait blocks until all pending forwards finish or the timeout elapses. It
+// reports whether every forward finished.
+func (t *ForwardTracker) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "runtime/debug"
+
+// Build information stamped at build time with
+// -ldflags "-X main.version=<version> -X main.commit=<sha> -X main.buildDate=<date>".
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo describes the running build.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+// CurrentBuild returns the stamped build information, falling back to the VCS
+// details recorded by the Go toolchain for builds without -ldflags.
+func CurrentBuild() BuildInfo {
+	info := BuildInfo{Version: version, Commit: commit, BuildDate: buildDate}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	return info
+}
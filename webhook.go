@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Webhook holds the data of a received webhook needed to forward it.
+type Webhook struct {
+	ID         string              `json:"id"`
+	RequestID  string              `json:"request_id"`
+	Repository string              `json:"repository"`
+	Tag        string              `json:"tag"`
+	Body       []byte              `json:"body"`
+	Headers    map[string][]string `json:"headers"`
+	// Format is the payload format the webhook was received in.
+	Format string `json:"format,omitempty"`
+	// Gzip is set when the body is forwarded gzip-compressed.
+	Gzip bool `json:"gzip,omitempty"`
+	// CallbackURL receives the forward outcome when DOCKERHUB_CALLBACK is set.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// Digest is the image digest recorded once the forward succeeded when
+	// DEDUP_DIGEST is set.
+	Digest string `json:"digest,omitempty"`
+
+	// TraceContext carries the webhook span to the asynchronous forward.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+// RequestIDHeader carries the request ID used to correlate a webhook across
+// systems.
+const RequestIDHeader = "X-Request-ID"
+
+// Logger returns a logger annotated with the webhook fields.
+func (h *Webhook) Logger() *slog.Logger {
+	return slog.With("webhook_id", h.ID, "request_id", h.RequestID, "repository", h.Repository, "tag", h.Tag)
+}
+
+// DefaultForwardHeaderAllowlist is the set of webhook headers forwarded to
+// Watchtower when FORWARD_HEADER_ALLOWLIST is not set.
+var DefaultForwardHeaderAllowlist = []string{"Content-Type"}
+
+// ParseHeaderAllowlist parses a comma-separated list of header names,
+// returning them in canonical form.
+func ParseHeaderAllowlist(value string) []string {
+	names := splitList(value)
+	if len(names) == 0 {
+		return DefaultForwardHeaderAllowlist
+	}
+	for i, name := range names {
+		names[i] = http.CanonicalHeaderKey(name)
+	}
+	return names
+}
+
+// allowedHeaders copies the headers named in allowlist. Authorization is
+// never copied since the forward carries its own credentials.
+func allowedHeaders(header http.Header, allowlist []string) map[string][]string {
+	headers := make(map[string][]string)
+	for _, name := range allowlist {
+		if values := header.Values(name); len(values) > 0 && name != "Authorization" {
+			headers[name] = values
+		}
+	}
+	return headers
+}
+
+// ForwardedForHeader lists the client addresses a request passed through.
+const ForwardedForHeader = "X-Forwarded-For"
+
+// appendForwardedFor adds the host of remoteAddr to the X-Forwarded-For
+// header, keeping the addresses added by earlier proxies.
+func appendForwardedFor(headers map[string][]string, remoteAddr string) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if existing := strings.Join(headers[ForwardedForHeader], ", "); existing != "" {
+		host = existing + ", " + host
+	}
+	headers[ForwardedForHeader] = []string{host}
+}